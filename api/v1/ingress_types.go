@@ -39,14 +39,39 @@ type IngressSpec struct {
 	Rules []netv1.IngressRule `json:"rules,omitempty" protobuf:"bytes,3,rep,name=rules"`
 }
 
+const (
+	// ConditionReady is true when the latest generation of the ingress is being served by nginx.
+	ConditionReady = "Ready"
+	// ConditionConfigApplied is true when the generated configuration passed `nginx -t` and was reloaded.
+	ConditionConfigApplied = "ConfigApplied"
+	// ConditionDegraded is true when the controller could not apply the latest generation of the ingress.
+	ConditionDegraded = "Degraded"
+)
+
 // IngressStatus defines the observed state of Ingress
 type IngressStatus struct {
-	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
-	// Important: Run "make" to regenerate code after modifying this file
+	// LoadBalancer contains the addresses published by the controller for this ingress.
+	// +optional
+	LoadBalancer netv1.IngressLoadBalancerStatus `json:"loadBalancer,omitempty"`
+	// ObservedGeneration is the most recent generation reconciled by the controller.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// ConfigHash is the SHA1 of the nginx configuration file last generated for this ingress.
+	// +optional
+	ConfigHash string `json:"configHash,omitempty"`
+	// Conditions describe whether the ingress has been rendered and reloaded into nginx.
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Address",type=string,JSONPath=`.status.loadBalancer.ingress[0].ip`
+//+kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+//+kubebuilder:printcolumn:name="Reason",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].reason`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // Ingress is the Schema for the ingresses API
 type Ingress struct {
@@ -91,9 +116,13 @@ type Backend struct {
 	Path           string                       `json:"path"`
 	ServiceBackend *netv1.IngressServiceBackend `json:"service_backend"`
 	Port           int32                        `json:"port"`
+	Endpoints      []string                     `json:"endpoints"`
 	TargetPath     string                       `json:"target_path"`
 	Annotations    ParseAnnotations             `json:"annotations"`
 	RewritePath    string                       `json:"rewrite_path"`
+	// Canary is the nginx variable holding the upstream picked between the backend and the canary
+	// ingress declaring the same host and path, empty without a canary
+	Canary string `json:"canary"`
 }
 
 func init() {
@@ -20,19 +20,34 @@ import (
 	"fmt"
 	v1 "k8s.io/api/networking/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"net/url"
 	"regexp"
 	ctrl "sigs.k8s.io/controller-runtime"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 	"strconv"
+	"strings"
 )
 
 const (
-	proxyPathAnnotation = "ingress.nginx.kubebuilder.io/proxy-host"
-	useWeightAnnotation = "ingress.nginx.kubebuilder.io/use-weight"
+	proxyPathAnnotation            = "ingress.nginx.kubebuilder.io/proxy-host"
+	useWeightAnnotation            = "ingress.nginx.kubebuilder.io/use-weight"
+	authTypeAnnotation             = "ingress.nginx.kubebuilder.io/auth-type"
+	authSecretAnnotation           = "ingress.nginx.kubebuilder.io/auth-secret"
+	authURLAnnotation              = "ingress.nginx.kubebuilder.io/auth-url"
+	authSigninAnnotation           = "ingress.nginx.kubebuilder.io/auth-signin"
+	serverSnippetAnnotation        = "ingress.nginx.kubebuilder.io/server-snippet"
+	configurationSnippetAnnotation = "ingress.nginx.kubebuilder.io/configuration-snippet"
 )
 
+// AllowSnippetAnnotations is set by the allow-snippet-annotations flag of the controller, the snippet
+// annotations are rejected unless it is
+var AllowSnippetAnnotations bool
+
+// deniedSnippetDirectives are the directives a snippet could run code or read the files of the controller with
+var deniedSnippetDirectives = regexp.MustCompile(`^(lua_\w*|\w*_by_lua\w*|load_module|include|root|alias)$`)
+
 // log is for logging in this package.
 var ingresslog = logf.Log.WithName("ingress-resource")
 
@@ -94,6 +109,14 @@ func (r *Ingress) ValidData() error {
 		return err
 	}
 
+	if err := r.ValidAuth(); err != nil {
+		return err
+	}
+
+	if err := r.ValidSnippets(); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -137,6 +160,182 @@ func (r *Ingress) ValidPathAndHost() error {
 	return nil
 }
 
+// ValidAuth rejects auth annotations nginx could not be configured with, the secret itself is checked by the controller
+func (r *Ingress) ValidAuth() error {
+	authType, ok := r.Annotations[authTypeAnnotation]
+	if ok && authType != "basic" {
+		return fmt.Errorf("auth-type: %s is not supported in ingress: %s, namespace: %s, only basic is", authType, r.Name, r.Namespace)
+	}
+
+	if ok && r.Annotations[authSecretAnnotation] == "" {
+		return fmt.Errorf("auth-type requires auth-secret in ingress: %s, namespace: %s", r.Name, r.Namespace)
+	}
+
+	for _, key := range []string{authURLAnnotation, authSigninAnnotation} {
+		val, ok := r.Annotations[key]
+		if !ok {
+			continue
+		}
+		u, err := url.Parse(val)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("%s: %s is not a valid http or https url in ingress: %s, namespace: %s", key, val, r.Name, r.Namespace)
+		}
+	}
+
+	if _, ok := r.Annotations[authSigninAnnotation]; ok && r.Annotations[authURLAnnotation] == "" {
+		return fmt.Errorf("auth-signin requires auth-url in ingress: %s, namespace: %s", r.Name, r.Namespace)
+	}
+
+	return nil
+}
+
+// ValidSnippets rejects the snippet annotations when they are disabled or use one of the denied directives
+func (r *Ingress) ValidSnippets() error {
+	for _, key := range []string{serverSnippetAnnotation, configurationSnippetAnnotation} {
+		val, ok := r.Annotations[key]
+		if !ok {
+			continue
+		}
+		if !AllowSnippetAnnotations {
+			return fmt.Errorf("%s is disabled by the controller in ingress: %s, namespace: %s", key, r.Name, r.Namespace)
+		}
+		if err := CheckSnippet(val); err != nil {
+			return fmt.Errorf("%s: %v in ingress: %s, namespace: %s", key, err, r.Name, r.Namespace)
+		}
+	}
+
+	return nil
+}
+
+// CheckSnippet returns an error when a directive of the nginx snippet is denied, the directive is the
+// first token of each statement and block. The blocks must be closed and the statements terminated,
+// so the snippet cannot leave the block it is rendered into or take over the directives following it.
+func CheckSnippet(snippet string) error {
+	tokens, err := snippetTokens(snippet)
+	if err != nil {
+		return err
+	}
+
+	depth := 0
+	statement := true
+	for _, token := range tokens {
+		switch {
+		case token == "{":
+			depth++
+			statement = true
+		case token == "}":
+			if depth--; depth < 0 {
+				return fmt.Errorf("unexpected }")
+			}
+			statement = true
+		case token == ";":
+			statement = true
+		case statement:
+			if deniedSnippetDirectives.MatchString(token) {
+				return fmt.Errorf("directive %s is not allowed in snippets", token)
+			}
+			statement = false
+		}
+	}
+
+	if depth > 0 {
+		return fmt.Errorf("unexpected end of snippet, expecting }")
+	}
+	if !statement {
+		return fmt.Errorf("unexpected end of snippet, expecting ;")
+	}
+
+	return nil
+}
+
+// snippetTokens splits a snippet the way nginx reads its configuration, the quotes and the comments are
+// dropped and ;, { and } are tokens of their own. Like nginx, quotes, # and } only start a token outside a
+// word, a word ends at a space, ; or {, and { is part of a word right after $.
+func snippetTokens(snippet string) ([]string, error) {
+	var (
+		tokens    []string
+		token     strings.Builder
+		lastSpace = true
+		needSpace bool
+		quote     rune
+		escaped   bool
+		variable  bool
+		comment   bool
+	)
+	flush := func() {
+		tokens = append(tokens, token.String())
+		token.Reset()
+	}
+	space := func(c rune) bool {
+		return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+	}
+
+	for _, c := range snippet {
+		switch {
+		case comment:
+			comment = c != '\n'
+		case escaped:
+			token.WriteRune(c)
+			escaped = false
+		case needSpace:
+			switch {
+			case space(c):
+				lastSpace, needSpace = true, false
+			case c == ';' || c == '{':
+				tokens = append(tokens, string(c))
+				lastSpace, needSpace = true, false
+			case c == ')':
+				// closes the condition of an if
+				lastSpace, needSpace = true, false
+			default:
+				return nil, fmt.Errorf("unexpected %q after a quoted string", c)
+			}
+		case lastSpace:
+			switch {
+			case space(c):
+			case c == ';' || c == '{' || c == '}':
+				tokens = append(tokens, string(c))
+			case c == '#':
+				comment = true
+			case c == '"' || c == '\'':
+				quote, lastSpace = c, false
+			case c == '\\':
+				escaped, lastSpace = true, false
+			default:
+				variable, lastSpace = c == '$', false
+				token.WriteRune(c)
+			}
+		case c == '{' && variable:
+			token.WriteRune(c)
+		case c == '\\':
+			escaped, variable = true, false
+		case quote != 0:
+			variable = c == '$'
+			if c != quote {
+				token.WriteRune(c)
+				continue
+			}
+			flush()
+			quote, needSpace = 0, true
+		case space(c) || c == ';' || c == '{':
+			flush()
+			if !space(c) {
+				tokens = append(tokens, string(c))
+			}
+			lastSpace, variable = true, false
+		default:
+			variable = c == '$'
+			token.WriteRune(c)
+		}
+	}
+
+	if quote != 0 || escaped || !lastSpace && !needSpace {
+		return nil, fmt.Errorf("unexpected end of snippet, expecting ;")
+	}
+
+	return tokens, nil
+}
+
 func (r *Ingress) ValidHost(str string) bool {
 	validHost := func(str string) bool {
 		p := `([a0-z9]+\.)+([a-z]+)`
@@ -17,6 +17,8 @@ limitations under the License.
 package v1
 
 import (
+	"testing"
+
 	. "github.com/onsi/ginkgo/v2"
 )
 
@@ -53,3 +55,48 @@ var _ = Describe("Ingress Webhook", func() {
 	})
 
 })
+
+func TestCheckSnippet(t *testing.T) {
+	tests := []struct {
+		name    string
+		snippet string
+		wantErr bool
+	}{
+		{name: "directive", snippet: "expires 1h;"},
+		{name: "quoted arguments", snippet: `more_set_headers "X-Server: edge" 'X-Env: prod';`},
+		{name: "nested block", snippet: "location /x {\n    return 200 \"ok\";\n}"},
+		{name: "if condition", snippet: `if ($http_x_debug = "1") { return 403; }`},
+		{name: "variable braces", snippet: `add_header X-Host ${host}x;`},
+		{name: "comment", snippet: "# root is denied\nexpires 1h;"},
+		{name: "brace inside a word", snippet: "return 200 a}b;"},
+		{name: "denied directive in quotes is an argument", snippet: `add_header X "root /;";`},
+
+		{name: "root", snippet: "root /etc;", wantErr: true},
+		{name: "alias", snippet: "location /x { alias /etc/; }", wantErr: true},
+		{name: "include", snippet: "include /etc/passwd;", wantErr: true},
+		{name: "load_module", snippet: "load_module modules/x.so;", wantErr: true},
+		{name: "lua block", snippet: "content_by_lua_block { ngx.say(1) }", wantErr: true},
+		{name: "lua file", snippet: "access_by_lua_file /tmp/x.lua;", wantErr: true},
+		{name: "lua directive", snippet: "lua_package_path /tmp/?.lua;", wantErr: true},
+		{name: "quoted directive", snippet: `"root" /etc;`, wantErr: true},
+		{name: "escaped directive", snippet: `\root /etc;`, wantErr: true},
+		{name: "directive after a quoted semicolon", snippet: `add_header X "a;" ; root /;`, wantErr: true},
+		{name: "quote inside a word", snippet: `add_header X a"; root /; "b;`, wantErr: true},
+		{name: "hash inside a word", snippet: "add_header X a#b; root /;", wantErr: true},
+		{name: "directive after a comment", snippet: "# comment\nroot /;", wantErr: true},
+		{name: "block escape", snippet: "} server { listen 8080; } location /y {", wantErr: true},
+		{name: "unbalanced close", snippet: "return 200; }", wantErr: true},
+		{name: "unclosed block", snippet: "location /x { return 200;", wantErr: true},
+		{name: "unterminated statement", snippet: "expires 1h", wantErr: true},
+		{name: "unterminated quote", snippet: `add_header X "a;`, wantErr: true},
+		{name: "text after a quoted string", snippet: `add_header X "a"b;`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := CheckSnippet(tt.snippet); (err != nil) != tt.wantErr {
+				t.Errorf("CheckSnippet(%q) error = %v, wantErr %v", tt.snippet, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -19,15 +19,19 @@ package main
 import (
 	"crypto/tls"
 	"flag"
+	"net"
 	"os"
+	"strconv"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/cache"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
@@ -35,7 +39,9 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
 	ingressv1 "github.com/Lxb921006/ingress-nginx-kubebuilder/api/v1"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/config"
 	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/controller"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/metrics"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -57,6 +63,13 @@ func main() {
 	var probeAddr string
 	var secureMetrics bool
 	var enableHTTP2 bool
+	var publishStatusAddress string
+	var enableVTS bool
+	var dynamicUpstreams bool
+	var allowSnippetAnnotations bool
+	var configMap string
+	var tcpServices string
+	var udpServices string
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
@@ -66,6 +79,28 @@ func main() {
 		"If set the metrics endpoint is served securely")
 	flag.BoolVar(&enableHTTP2, "enable-http2", false,
 		"If set, HTTP/2 will be enabled for the metrics and webhook servers")
+	flag.StringVar(&publishStatusAddress, "publish-status-address", "",
+		"Comma separated list of ips or hostnames written to the status of the managed ingresses. "+
+			"Defaults to the ip of the controller pod.")
+	flag.BoolVar(&enableVTS, "enable-vts", false,
+		"If set, nginx records per upstream traffic with the vhost traffic status module and the controller "+
+			"exports it. Requires nginx built with nginx-module-vts.")
+	flag.BoolVar(&dynamicUpstreams, "dynamic-upstreams", false,
+		"If set, the pods behind the upstreams are pushed to a lua balancer in nginx, so endpoint and weight "+
+			"changes do not reload nginx. Requires nginx built with lua-nginx-module and lua-resty-core, e.g. OpenResty.")
+	flag.BoolVar(&allowSnippetAnnotations, "allow-snippet-annotations", false,
+		"If set, the server-snippet and configuration-snippet annotations are injected into the nginx configuration. "+
+			"Their users can configure nginx as the controller, enable it only when they are trusted.")
+	flag.StringVar(&configMap, "configmap", "",
+		"Namespace/name of the ConfigMap holding the global nginx settings, e.g. worker-processes, keep-alive, "+
+			"gzip, log-format-upstream, proxy-body-size or ssl-protocols.")
+	flag.StringVar(&tcpServices, "tcp-services-configmap", "",
+		"Namespace/name of the ConfigMap mapping the tcp ports nginx listens on to namespace/service:port, "+
+			"optionally followed by :PROXY to accept and :PROXY to send the PROXY protocol. The ports must be "+
+			"exposed by the service of the controller. Requires nginx built with the stream module.")
+	flag.StringVar(&udpServices, "udp-services-configmap", "",
+		"Namespace/name of the ConfigMap mapping the udp ports nginx listens on to namespace/service:port. "+
+			"The ports must be exposed by the service of the controller. Requires nginx built with the stream module.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -122,14 +157,82 @@ func main() {
 		os.Exit(1)
 	}
 
+	config.ReservePorts(addressPort(metricsAddr), addressPort(probeAddr))
+
+	global := config.NewGlobal()
+	global.EnableVTS = enableVTS
+	global.DynamicUpstreams = dynamicUpstreams
+	global.AllowSnippetAnnotations = allowSnippetAnnotations
+	ingressv1.AllowSnippetAnnotations = allowSnippetAnnotations
+	global.Stream = tcpServices != "" || udpServices != ""
+	config.SetGlobal(global)
+	metrics.RegisterNginxStatusCollector(enableVTS)
+
 	if err = (&controller.IngressReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:               mgr.GetClient(),
+		Scheme:               mgr.GetScheme(),
+		Recorder:             mgr.GetEventRecorderFor("ingress-nginx-controller"),
+		PublishStatusAddress: publishStatusAddress,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Ingress")
 		os.Exit(1)
 	}
 
+	if err = (&controller.CoreIngressReconciler{
+		IngressReconciler: controller.IngressReconciler{
+			Client:               mgr.GetClient(),
+			Scheme:               mgr.GetScheme(),
+			Recorder:             mgr.GetEventRecorderFor("ingress-nginx-controller"),
+			PublishStatusAddress: publishStatusAddress,
+		},
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "networking.k8s.io/v1 Ingress")
+		os.Exit(1)
+	}
+
+	if configMap != "" {
+		namespace, name, err := cache.SplitMetaNamespaceKey(configMap)
+		if err != nil || namespace == "" || name == "" {
+			setupLog.Error(err, "--configmap must be namespace/name", "configmap", configMap)
+			os.Exit(1)
+		}
+
+		if err = (&controller.ConfigMapReconciler{
+			Client:    mgr.GetClient(),
+			Recorder:  mgr.GetEventRecorderFor("ingress-nginx-controller"),
+			ConfigMap: types.NamespacedName{Namespace: namespace, Name: name},
+			Base:      global,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "ConfigMap")
+			os.Exit(1)
+		}
+	}
+
+	if global.Stream {
+		streams := make(map[string]types.NamespacedName)
+		for flagName, val := range map[string]string{"tcp-services-configmap": tcpServices, "udp-services-configmap": udpServices} {
+			if val == "" {
+				continue
+			}
+			namespace, name, err := cache.SplitMetaNamespaceKey(val)
+			if err != nil || namespace == "" || name == "" {
+				setupLog.Error(err, "--"+flagName+" must be namespace/name", "configmap", val)
+				os.Exit(1)
+			}
+			streams[flagName] = types.NamespacedName{Namespace: namespace, Name: name}
+		}
+
+		if err = (&controller.StreamReconciler{
+			Client:      mgr.GetClient(),
+			Recorder:    mgr.GetEventRecorderFor("ingress-nginx-controller"),
+			TCPServices: streams["tcp-services-configmap"],
+			UDPServices: streams["udp-services-configmap"],
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "Stream")
+			os.Exit(1)
+		}
+	}
+
 	if err = (&ingressv1.Ingress{}).SetupWebhookWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create webhook", "webhook", "Ingress")
 		os.Exit(1)
@@ -152,3 +255,14 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// addressPort returns the port of a bind address, 0 when the server is disabled
+func addressPort(addr string) int {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return 0
+	}
+
+	p, _ := strconv.Atoi(port)
+	return p
+}
@@ -0,0 +1,167 @@
+package affinity
+
+import (
+	"fmt"
+	ingressv1 "github.com/Lxb921006/ingress-nginx-kubebuilder/api/v1"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations/parser"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations/resolver"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/errors"
+	"hash/fnv"
+	"strings"
+)
+
+const (
+	affinityAnnotation              = "affinity"
+	sessionCookieNameAnnotation     = "session-cookie-name"
+	sessionCookiePathAnnotation     = "session-cookie-path"
+	sessionCookieMaxAgeAnnotation   = "session-cookie-max-age"
+	sessionCookieSecureAnnotation   = "session-cookie-secure"
+	sessionCookieSameSiteAnnotation = "session-cookie-samesite"
+	upstreamHashByAnnotation        = "upstream-hash-by"
+	defaultCookieName               = "INGRESSCOOKIE"
+	defaultCookiePath               = "/"
+)
+
+const (
+	ModeCookie = "cookie"
+	ModeIPHash = "ip-hash"
+)
+
+var affinityAnnotations = parser.Annotation{
+	Group: "affinity",
+	Annotations: parser.AnnotationFields{
+		affinityAnnotation: {
+			Doc:       "send the requests of a client to the same endpoint, by a cookie set by nginx or by the client ip, e.g: `cookie or ip-hash`, optional",
+			Validator: parser.ValidateRegex(`^(cookie|ip-hash)$`),
+		},
+		sessionCookieNameAnnotation: {
+			Doc:       "name of the affinity cookie, e.g: `route`, optional, defaults to INGRESSCOOKIE",
+			Validator: parser.ValidateRegex(`^[A-Za-z0-9_]+$`),
+		},
+		sessionCookiePathAnnotation: {
+			Doc:       "path of the affinity cookie, e.g: `/app`, optional, defaults to /",
+			Validator: parser.ValidateRegex(`^/[^\s"'{};\\]*$`),
+		},
+		sessionCookieMaxAgeAnnotation: {
+			Doc:       "seconds the browser keeps the affinity cookie, e.g: `3600`, optional, defaults to the browser session",
+			Validator: parser.ValidateIntRange(1, 315360000),
+		},
+		sessionCookieSecureAnnotation: {
+			Doc: "the affinity cookie is only sent over https, e.g: `true or false`, optional",
+		},
+		sessionCookieSameSiteAnnotation: {
+			Doc:       "SameSite attribute of the affinity cookie, `None` requires session-cookie-secure, e.g: `Strict, Lax or None`, optional",
+			Validator: parser.ValidateRegex(`^(Strict|Lax|None)$`),
+		},
+		upstreamHashByAnnotation: {
+			Doc:       "nginx variable the endpoint is picked by with consistent hashing, e.g: `$request_uri` or `$http_x_user`, optional",
+			Validator: parser.ValidateRegex(`^\$[A-Za-z0-9_]+$`),
+		},
+	},
+}
+
+// Config picks the endpoint of the upstreams of the ingress from a key of the request instead of round robin
+type Config struct {
+	Mode           string `json:"mode"`
+	CookieName     string `json:"cookie-name"`
+	CookiePath     string `json:"cookie-path"`
+	CookieMaxAge   int    `json:"cookie-max-age"`
+	CookieSecure   bool   `json:"cookie-secure"`
+	CookieSameSite string `json:"cookie-samesite"`
+	HashBy         string `json:"hash-by"`
+	// Name prefixes the variables of the ingress set at http level
+	Name string `json:"name"`
+}
+
+type affinity struct {
+	r resolver.Resolver
+}
+
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return &affinity{}
+}
+
+func (a *affinity) Parse(ing *ingressv1.Ingress) (interface{}, error) {
+	config := &Config{
+		CookieName: defaultCookieName,
+		CookiePath: defaultCookiePath,
+	}
+
+	for name, field := range map[string]*string{
+		affinityAnnotation:              &config.Mode,
+		sessionCookieNameAnnotation:     &config.CookieName,
+		sessionCookiePathAnnotation:     &config.CookiePath,
+		sessionCookieSameSiteAnnotation: &config.CookieSameSite,
+		upstreamHashByAnnotation:        &config.HashBy,
+	} {
+		val, err := parser.GetStringAnnotation(name, ing, affinityAnnotations.Annotations)
+		if err != nil {
+			if errors.IsInvalidAnnotationsContentError(err) {
+				return nil, err
+			}
+			continue
+		}
+		*field = val
+	}
+
+	maxAge, err := parser.GetIntAnnotation(sessionCookieMaxAgeAnnotation, ing, affinityAnnotations.Annotations)
+	if err != nil {
+		if errors.IsInvalidAnnotationsContentError(err) {
+			return nil, err
+		}
+	} else {
+		config.CookieMaxAge = maxAge
+	}
+
+	config.CookieSecure, _ = parser.GetBoolAnnotations(sessionCookieSecureAnnotation, ing, affinityAnnotations.Annotations)
+
+	if config.Mode != "" && config.HashBy != "" {
+		msg := fmt.Sprintf("annotation %s can not be used together with %s, ingress name %s", affinityAnnotation, upstreamHashByAnnotation, ing.Name)
+		return nil, errors.NewNotSatisfiableError(msg)
+	}
+
+	if config.CookieSameSite == "None" && !config.CookieSecure {
+		msg := fmt.Sprintf("annotation %s None requires %s, ingress name %s", sessionCookieSameSiteAnnotation, sessionCookieSecureAnnotation, ing.Name)
+		return nil, errors.NewNotSatisfiableError(msg)
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(ing.Namespace + "/" + ing.Name))
+	config.Name = fmt.Sprintf("affinity_%08x", h.Sum32())
+
+	return config, nil
+}
+
+func (a *affinity) Validate(anns map[string]string) error {
+	return parser.CheckAnnotations(anns, affinityAnnotations.Annotations)
+}
+
+// Key returns the variable, without the $, the endpoint is picked by, empty when the requests are balanced
+func (c Config) Key() string {
+	switch {
+	case c.Mode == ModeCookie:
+		return c.Name + "_key"
+	case c.Mode == ModeIPHash:
+		return "remote_addr"
+	case c.HashBy != "":
+		return strings.TrimPrefix(c.HashBy, "$")
+	}
+
+	return ""
+}
+
+// SetCookie returns the Set-Cookie header issuing the affinity cookie, its value is the key of the first request
+func (c Config) SetCookie() string {
+	cookie := fmt.Sprintf("%s=$request_id; Path=%s; HttpOnly", c.CookieName, c.CookiePath)
+	if c.CookieMaxAge > 0 {
+		cookie += fmt.Sprintf("; Max-Age=%d", c.CookieMaxAge)
+	}
+	if c.CookieSecure {
+		cookie += "; Secure"
+	}
+	if c.CookieSameSite != "" {
+		cookie += "; SameSite=" + c.CookieSameSite
+	}
+
+	return cookie
+}
@@ -0,0 +1,117 @@
+package affinity
+
+import (
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations/testutil"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/errors"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	// name is derived from the namespace and name of the ingress
+	const name = "affinity_d878a988"
+
+	tests := []struct {
+		name          string
+		anns          map[string]string
+		wantKey       string
+		wantSetCookie string
+		wantErr       bool
+	}{
+		{
+			name: "balanced",
+			anns: map[string]string{},
+		},
+		{
+			name:          "cookie",
+			anns:          map[string]string{affinityAnnotation: ModeCookie},
+			wantKey:       name + "_key",
+			wantSetCookie: "INGRESSCOOKIE=$request_id; Path=/; HttpOnly",
+		},
+		{
+			name: "cookie attributes",
+			anns: map[string]string{
+				affinityAnnotation:              ModeCookie,
+				sessionCookieNameAnnotation:     "route",
+				sessionCookiePathAnnotation:     "/app",
+				sessionCookieMaxAgeAnnotation:   "3600",
+				sessionCookieSecureAnnotation:   "true",
+				sessionCookieSameSiteAnnotation: "None",
+			},
+			wantKey:       name + "_key",
+			wantSetCookie: "route=$request_id; Path=/app; HttpOnly; Max-Age=3600; Secure; SameSite=None",
+		},
+		{
+			name:    "ip hash",
+			anns:    map[string]string{affinityAnnotation: ModeIPHash},
+			wantKey: "remote_addr",
+		},
+		{
+			name:    "hash by",
+			anns:    map[string]string{upstreamHashByAnnotation: "$http_x_user"},
+			wantKey: "http_x_user",
+		},
+		{
+			name:    "unknown mode",
+			anns:    map[string]string{affinityAnnotation: "header"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid cookie name",
+			anns:    map[string]string{affinityAnnotation: ModeCookie, sessionCookieNameAnnotation: "route=1"},
+			wantErr: true,
+		},
+		{
+			name:    "cookie path with a directive",
+			anns:    map[string]string{affinityAnnotation: ModeCookie, sessionCookiePathAnnotation: "/app; HttpOnly=false"},
+			wantErr: true,
+		},
+		{
+			name:    "max age out of range",
+			anns:    map[string]string{affinityAnnotation: ModeCookie, sessionCookieMaxAgeAnnotation: "0"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown samesite",
+			anns:    map[string]string{affinityAnnotation: ModeCookie, sessionCookieSameSiteAnnotation: "strict"},
+			wantErr: true,
+		},
+		{
+			name:    "hash by an expression",
+			anns:    map[string]string{upstreamHashByAnnotation: "$host$request_uri"},
+			wantErr: true,
+		},
+		{
+			name:    "mode and hash by",
+			anns:    map[string]string{affinityAnnotation: ModeIPHash, upstreamHashByAnnotation: "$request_uri"},
+			wantErr: true,
+		},
+		{
+			name:    "samesite none without secure",
+			anns:    map[string]string{affinityAnnotation: ModeCookie, sessionCookieSameSiteAnnotation: "None"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewParser(nil).Parse(testutil.Ingress(tt.anns))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if !errors.IsInvalidAnnotationsContentError(err) && !errors.IsNotSatisfiableError(err) {
+					t.Errorf("Parse() error = %v, want an invalid or not satisfiable annotation error", err)
+				}
+				return
+			}
+
+			c := got.(*Config)
+			if key := c.Key(); key != tt.wantKey {
+				t.Errorf("Key() = %s, want %s", key, tt.wantKey)
+			}
+			if tt.wantSetCookie != "" && c.SetCookie() != tt.wantSetCookie {
+				t.Errorf("SetCookie() = %s, want %s", c.SetCookie(), tt.wantSetCookie)
+			}
+		})
+	}
+}
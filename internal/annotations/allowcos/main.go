@@ -1,28 +1,80 @@
 package allowcos
 
 import (
+	"fmt"
 	ingressv1 "github.com/Lxb921006/ingress-nginx-kubebuilder/api/v1"
 	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations/parser"
 	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations/resolver"
 	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/errors"
+	"hash/fnv"
 	"k8s.io/klog/v2"
+	"regexp"
+	"strings"
 )
 
 const (
-	allowCosAnnotation = "use-cos"
+	allowCosAnnotation             = "use-cos"
+	corsAllowOriginAnnotation      = "cors-allow-origin"
+	corsAllowMethodsAnnotation     = "cors-allow-methods"
+	corsAllowHeadersAnnotation     = "cors-allow-headers"
+	corsExposeHeadersAnnotation    = "cors-expose-headers"
+	corsAllowCredentialsAnnotation = "cors-allow-credentials"
+	corsMaxAgeAnnotation           = "cors-max-age"
 )
 
+const (
+	defaultAllowMethods  = "GET, POST, OPTIONS"
+	defaultAllowHeaders  = "DNT,User-Agent,X-Requested-With,If-Modified-Since,Cache-Control,Content-Type,Range,xfilecategory,xfilename,xfilesize"
+	defaultExposeHeaders = "Content-Length,Content-Range"
+	defaultMaxAge        = 1728000
+)
+
+var originRegex = regexp.MustCompile(`^(https?)://(\*\.)?([A-Za-z0-9-]+(\.[A-Za-z0-9-]+)*)(:\d+)?$`)
+
 var cosAnnotation = parser.Annotation{
 	Group: "allowCos",
 	Annotations: parser.AnnotationFields{
 		allowCosAnnotation: {
 			Doc: "allow cos, e.g: `true or false`, required",
 		},
+		corsAllowOriginAnnotation: {
+			Doc:       "origins allowed to send cross-origin requests, `*.` matches any subdomain, e.g: `https://a.com, https://*.b.com`, optional, defaults to `*`",
+			Validator: validateOrigins,
+		},
+		corsAllowMethodsAnnotation: {
+			Doc:       "methods allowed in cross-origin requests, e.g: `GET, PUT, POST`, optional, defaults to `GET, POST, OPTIONS`",
+			Validator: parser.ValidateRegex(`^[A-Z]+(\s*,\s*[A-Z]+)*$`),
+		},
+		corsAllowHeadersAnnotation: {
+			Doc:       "headers allowed in cross-origin requests, e.g: `Authorization, Content-Type`, optional",
+			Validator: parser.ValidateRegex(`^(\*|[A-Za-z0-9_-]+(\s*,\s*[A-Za-z0-9_-]+)*)$`),
+		},
+		corsExposeHeadersAnnotation: {
+			Doc:       "headers of the response exposed to the browser, e.g: `Content-Length, X-Request-Id`, optional",
+			Validator: parser.ValidateRegex(`^(\*|[A-Za-z0-9_-]+(\s*,\s*[A-Za-z0-9_-]+)*)$`),
+		},
+		corsAllowCredentialsAnnotation: {
+			Doc: "allow cookies and authorization headers in cross-origin requests, requires a cors-allow-origin list, e.g: `true or false`, optional",
+		},
+		corsMaxAgeAnnotation: {
+			Doc:       "seconds the browser caches the response of a preflight request, e.g: `600`, optional, defaults to 1728000",
+			Validator: parser.ValidateIntRange(0, 86400000),
+		},
 	},
 }
 
 type Config struct {
-	AllowCos bool `json:"allow_cos"`
+	AllowCos         bool     `json:"allow_cos"`
+	AllowOrigin      []string `json:"allow_origin"`
+	AllowMethods     string   `json:"allow_methods"`
+	AllowHeaders     string   `json:"allow_headers"`
+	ExposeHeaders    string   `json:"expose_headers"`
+	AllowCredentials bool     `json:"allow_credentials"`
+	MaxAge           int      `json:"max_age"`
+	// Origins are the keys of the map setting OriginVariable to the origin of an allowed request,
+	// OriginVariable is empty when every origin is allowed
+	Origins        []string `json:"origins"`
+	OriginVariable string   `json:"origin_variable"`
 }
 
 type redirect struct {
@@ -35,7 +87,12 @@ func NewParser(r resolver.Resolver) parser.IngressAnnotation {
 
 func (r *redirect) Parse(ing *ingressv1.Ingress) (interface{}, error) {
 	var err error
-	config := &Config{}
+	config := &Config{
+		AllowMethods:  defaultAllowMethods,
+		AllowHeaders:  defaultAllowHeaders,
+		ExposeHeaders: defaultExposeHeaders,
+		MaxAge:        defaultMaxAge,
+	}
 	config.AllowCos, err = parser.GetBoolAnnotations(allowCosAnnotation, ing, cosAnnotation.Annotations)
 	if err != nil {
 		if errors.IsValidationError(err) {
@@ -43,9 +100,88 @@ func (r *redirect) Parse(ing *ingressv1.Ingress) (interface{}, error) {
 		}
 	}
 
+	if !config.AllowCos {
+		return config, nil
+	}
+
+	var origins string
+	for name, field := range map[string]*string{
+		corsAllowOriginAnnotation:   &origins,
+		corsAllowMethodsAnnotation:  &config.AllowMethods,
+		corsAllowHeadersAnnotation:  &config.AllowHeaders,
+		corsExposeHeadersAnnotation: &config.ExposeHeaders,
+	} {
+		val, err := parser.GetStringAnnotation(name, ing, cosAnnotation.Annotations)
+		if err != nil {
+			if errors.IsInvalidAnnotationsContentError(err) {
+				return nil, err
+			}
+			continue
+		}
+		*field = val
+	}
+
+	config.AllowCredentials, _ = parser.GetBoolAnnotations(corsAllowCredentialsAnnotation, ing, cosAnnotation.Annotations)
+
+	maxAge, err := parser.GetIntAnnotation(corsMaxAgeAnnotation, ing, cosAnnotation.Annotations)
+	if err != nil {
+		if errors.IsInvalidAnnotationsContentError(err) {
+			return nil, err
+		}
+	} else {
+		config.MaxAge = maxAge
+	}
+
+	for _, origin := range strings.Split(origins, ",") {
+		if origin = strings.TrimSpace(origin); origin == "" {
+			continue
+		}
+		// any origin is allowed
+		if origin == "*" {
+			config.AllowOrigin, config.Origins = nil, nil
+			break
+		}
+		config.AllowOrigin = append(config.AllowOrigin, origin)
+		config.Origins = append(config.Origins, originKey(origin))
+	}
+
+	if len(config.AllowOrigin) == 0 {
+		if config.AllowCredentials {
+			msg := fmt.Sprintf("annotation %s requires a %s list, browsers reject credentials sent to any origin, ingress name %s", corsAllowCredentialsAnnotation, corsAllowOriginAnnotation, ing.Name)
+			return nil, errors.NewNotSatisfiableError(msg)
+		}
+		return config, nil
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(ing.Namespace + "/" + ing.Name))
+	config.OriginVariable = fmt.Sprintf("cors_%08x", h.Sum32())
+
 	return config, nil
 }
 
 func (r *redirect) Validate(anns map[string]string) error {
 	return parser.CheckAnnotations(anns, cosAnnotation.Annotations)
 }
+
+// originKey returns the map key matching an origin, a regex for the wildcard subdomains
+// e.g: https://*.b.com is ~^https://[A-Za-z0-9-]+(\.[A-Za-z0-9-]+)*\.b\.com$
+func originKey(origin string) string {
+	m := originRegex.FindStringSubmatch(origin)
+	if m[2] == "" {
+		return `"` + origin + `"`
+	}
+
+	return `"~^` + m[1] + `://[A-Za-z0-9-]+(\.[A-Za-z0-9-]+)*\.` + regexp.QuoteMeta(m[3]+m[5]) + `$"`
+}
+
+// validateOrigins accepts `*` or a list of http and https origins
+func validateOrigins(val string) error {
+	for _, origin := range strings.Split(val, ",") {
+		if origin = strings.TrimSpace(origin); origin != "*" && !originRegex.MatchString(origin) {
+			return fmt.Errorf("%s is not a valid origin", origin)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,112 @@
+package allowcos
+
+import (
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations/testutil"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	// variable is derived from the namespace and name of the ingress
+	const variable = "cors_d878a988"
+
+	defaults := Config{
+		AllowMethods:  defaultAllowMethods,
+		AllowHeaders:  defaultAllowHeaders,
+		ExposeHeaders: defaultExposeHeaders,
+		MaxAge:        defaultMaxAge,
+	}
+
+	tests := []struct {
+		name    string
+		anns    map[string]string
+		want    func(c *Config)
+		wantErr bool
+	}{
+		{
+			name: "disabled",
+			anns: map[string]string{corsAllowOriginAnnotation: "https://a.com"},
+			want: func(c *Config) {},
+		},
+		{
+			name: "any origin",
+			anns: map[string]string{allowCosAnnotation: "true"},
+			want: func(c *Config) { c.AllowCos = true },
+		},
+		{
+			name: "policy",
+			anns: map[string]string{
+				allowCosAnnotation:             "true",
+				corsAllowOriginAnnotation:      "https://a.com, https://*.b.com:8443",
+				corsAllowMethodsAnnotation:     "GET, PUT",
+				corsAllowHeadersAnnotation:     "Authorization,Content-Type",
+				corsExposeHeadersAnnotation:    "*",
+				corsAllowCredentialsAnnotation: "true",
+				corsMaxAgeAnnotation:           "600",
+			},
+			want: func(c *Config) {
+				c.AllowCos = true
+				c.AllowOrigin = []string{"https://a.com", "https://*.b.com:8443"}
+				c.AllowMethods = "GET, PUT"
+				c.AllowHeaders = "Authorization,Content-Type"
+				c.ExposeHeaders = "*"
+				c.AllowCredentials = true
+				c.MaxAge = 600
+				c.Origins = []string{`"https://a.com"`, `"~^https://[A-Za-z0-9-]+(\.[A-Za-z0-9-]+)*\.b\.com:8443$"`}
+				c.OriginVariable = variable
+			},
+		},
+		{
+			name: "wildcard in the list allows any origin",
+			anns: map[string]string{allowCosAnnotation: "true", corsAllowOriginAnnotation: "https://a.com,*"},
+			want: func(c *Config) { c.AllowCos = true },
+		},
+		{
+			name:    "credentials for any origin",
+			anns:    map[string]string{allowCosAnnotation: "true", corsAllowCredentialsAnnotation: "true"},
+			wantErr: true,
+		},
+		{
+			name:    "origin with a path",
+			anns:    map[string]string{allowCosAnnotation: "true", corsAllowOriginAnnotation: "https://a.com/app"},
+			wantErr: true,
+		},
+		{
+			name:    "origin breaks out of the map",
+			anns:    map[string]string{allowCosAnnotation: "true", corsAllowOriginAnnotation: `https://a.com" 1; default "`},
+			wantErr: true,
+		},
+		{
+			name:    "lower case method",
+			anns:    map[string]string{allowCosAnnotation: "true", corsAllowMethodsAnnotation: "get"},
+			wantErr: true,
+		},
+		{
+			name:    "header with a directive",
+			anns:    map[string]string{allowCosAnnotation: "true", corsAllowHeadersAnnotation: "X-A; add_header X-B 1"},
+			wantErr: true,
+		},
+		{
+			name:    "max age out of range",
+			anns:    map[string]string{allowCosAnnotation: "true", corsMaxAgeAnnotation: "-1"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewParser(nil).Parse(testutil.Ingress(tt.anns))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			want := defaults
+			tt.want(&want)
+			if !reflect.DeepEqual(got, &want) {
+				t.Errorf("Parse() = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
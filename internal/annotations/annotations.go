@@ -3,14 +3,26 @@ package annotations
 import (
 	"fmt"
 	ingressv1 "github.com/Lxb921006/ingress-nginx-kubebuilder/api/v1"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations/affinity"
 	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations/allowcos"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations/auth"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations/authtls"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations/backendprotocol"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations/canary"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations/customerrors"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations/headers"
 	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations/ipallowlist"
 	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations/ipdenylist"
 	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations/parser"
 	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations/proxy"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations/proxysettings"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations/ratelimit"
 	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations/redirect"
 	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations/resolver"
 	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations/rewrite"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations/serviceupstream"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations/snippet"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations/sslpolicy"
 	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations/sslstapling"
 	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations/weight"
 	kerr "github.com/Lxb921006/ingress-nginx-kubebuilder/internal/errors"
@@ -29,14 +41,26 @@ type IngressAnnotations struct {
 
 type Ingress struct {
 	metav1.ObjectMeta
-	Proxy       proxy.Config
-	Rewrite     rewrite.Config
-	Redirect    redirect.Config
-	SSLStapling sslstapling.Config
-	AllowList   ipallowlist.SourceRange
-	DenyList    ipdenylist.SourceRange
-	AllowCos    allowcos.Config
-	Weight      weight.BackendWeight
+	Proxy           proxy.Config
+	Rewrite         rewrite.Config
+	Redirect        redirect.Config
+	SSLStapling     sslstapling.Config
+	AllowList       ipallowlist.SourceRange
+	DenyList        ipdenylist.SourceRange
+	AllowCos        allowcos.Config
+	Weight          weight.BackendWeight
+	Upstream        serviceupstream.Config
+	ProxySettings   proxysettings.Config
+	Canary          canary.Config
+	Auth            auth.Config
+	RateLimit       ratelimit.Config
+	SSLPolicy       sslpolicy.Config
+	AuthTLS         authtls.Config
+	BackendProtocol backendprotocol.Config
+	Affinity        affinity.Config
+	CustomErrors    customerrors.Config
+	Snippet         snippet.Config
+	Headers         headers.Config
 }
 
 func (*Ingress) GetIngressAnnotations() {}
@@ -44,14 +68,26 @@ func (*Ingress) GetIngressAnnotations() {}
 func NewAnnotationExtractor(r resolver.Resolver) *Extractor {
 	return &Extractor{
 		map[string]parser.IngressAnnotation{
-			"Proxy":       proxy.NewParser(r),
-			"Redirect":    redirect.NewParser(r),
-			"AllowList":   ipallowlist.NewParser(r),
-			"DenyList":    ipdenylist.NewParser(r),
-			"Rewrite":     rewrite.NewParser(r),
-			"SSLStapling": sslstapling.NewParser(r),
-			"AllowCos":    allowcos.NewParser(r),
-			"Weight":      weight.NewParser(r),
+			"Proxy":           proxy.NewParser(r),
+			"Redirect":        redirect.NewParser(r),
+			"AllowList":       ipallowlist.NewParser(r),
+			"DenyList":        ipdenylist.NewParser(r),
+			"Rewrite":         rewrite.NewParser(r),
+			"SSLStapling":     sslstapling.NewParser(r),
+			"AllowCos":        allowcos.NewParser(r),
+			"Weight":          weight.NewParser(r),
+			"Upstream":        serviceupstream.NewParser(r),
+			"ProxySettings":   proxysettings.NewParser(r),
+			"Canary":          canary.NewParser(r),
+			"Auth":            auth.NewParser(r),
+			"RateLimit":       ratelimit.NewParser(r),
+			"SSLPolicy":       sslpolicy.NewParser(r),
+			"AuthTLS":         authtls.NewParser(r),
+			"BackendProtocol": backendprotocol.NewParser(r),
+			"Affinity":        affinity.NewParser(r),
+			"CustomErrors":    customerrors.NewParser(r),
+			"Snippet":         snippet.NewParser(r),
+			"Headers":         headers.NewParser(r),
 		},
 	}
 }
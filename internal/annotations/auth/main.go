@@ -0,0 +1,163 @@
+package auth
+
+import (
+	"fmt"
+	ingressv1 "github.com/Lxb921006/ingress-nginx-kubebuilder/api/v1"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations/parser"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations/resolver"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/errors"
+	"k8s.io/klog/v2"
+	"net/url"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"strings"
+)
+
+const (
+	authTypeAnnotation            = "auth-type"
+	authSecretAnnotation          = "auth-secret"
+	authRealmAnnotation           = "auth-realm"
+	authURLAnnotation             = "auth-url"
+	authSigninAnnotation          = "auth-signin"
+	authResponseHeadersAnnotation = "auth-response-headers"
+	// htpasswdKey is the key of the auth secret holding the htpasswd entries
+	htpasswdKey  = "auth"
+	defaultRealm = "Authentication Required"
+)
+
+var authAnnotations = parser.Annotation{
+	Group: "auth",
+	Annotations: parser.AnnotationFields{
+		authTypeAnnotation: {
+			Doc:       "type of the authentication, only `basic` is supported, requires auth-secret, optional",
+			Validator: parser.ValidateRegex(`^basic$`),
+		},
+		authSecretAnnotation: {
+			Doc:       "secret of the namespace of the ingress holding the htpasswd entries under the key `auth`, e.g: `basic-auth`, required with auth-type",
+			Validator: parser.ValidateRegex(`^[a-z0-9]([-a-z0-9.]*[a-z0-9])?$`),
+		},
+		authRealmAnnotation: {
+			Doc:       "realm shown by the browser when asking for the credentials, e.g: `Authentication Required`, optional",
+			Validator: parser.ValidateRegex(`^[^"\\;{}\n]+$`),
+		},
+		authURLAnnotation: {
+			Doc:       "url of the external service authenticating the requests, a 2xx response allows them, e.g: `http://auth.default.svc/verify`, optional",
+			Validator: validateURL,
+		},
+		authSigninAnnotation: {
+			Doc:       "url the requests rejected by auth-url are redirected to, the original url is passed as `rd`, escaped when nginx runs lua (--dynamic-upstreams), e.g: `https://auth.example.com/signin`, optional",
+			Validator: validateURL,
+		},
+		authResponseHeadersAnnotation: {
+			Doc:       "headers of the response of auth-url passed to the backend, e.g: `X-User,X-Email`, optional",
+			Validator: parser.ValidateRegex(`^[A-Za-z0-9-]+(\s*,\s*[A-Za-z0-9-]+)*$`),
+		},
+	},
+}
+
+// Config protects the locations of the ingress with basic authentication and/or an external auth service
+type Config struct {
+	Type   string `json:"type"`
+	Secret string `json:"secret"`
+	Realm  string `json:"realm"`
+	// File is the htpasswd file written by the controller from Htpasswd
+	File            string           `json:"file"`
+	Htpasswd        []byte           `json:"-"`
+	URL             string           `json:"url"`
+	Signin          string           `json:"signin"`
+	ResponseHeaders []ResponseHeader `json:"response-headers"`
+}
+
+// ResponseHeader is a header of the response of auth-url passed to the backend
+type ResponseHeader struct {
+	Name string `json:"name"`
+	// Variable is the nginx variable of the header in the auth response, e.g: X-User is upstream_http_x_user
+	Variable string `json:"variable"`
+}
+
+type auth struct {
+	r resolver.Resolver
+}
+
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return &auth{
+		r: r,
+	}
+}
+
+func (a *auth) Parse(ing *ingressv1.Ingress) (interface{}, error) {
+	config := &Config{}
+
+	for name, field := range map[string]*string{
+		authTypeAnnotation:   &config.Type,
+		authSecretAnnotation: &config.Secret,
+		authRealmAnnotation:  &config.Realm,
+		authURLAnnotation:    &config.URL,
+		authSigninAnnotation: &config.Signin,
+	} {
+		val, err := parser.GetStringAnnotation(name, ing, authAnnotations.Annotations)
+		if err != nil {
+			if errors.IsInvalidAnnotationsContentError(err) {
+				return nil, err
+			}
+			continue
+		}
+		*field = val
+	}
+
+	headers, err := parser.GetStringAnnotation(authResponseHeadersAnnotation, ing, authAnnotations.Annotations)
+	if err != nil && errors.IsInvalidAnnotationsContentError(err) {
+		return nil, err
+	}
+	for _, h := range strings.Split(headers, ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			config.ResponseHeaders = append(config.ResponseHeaders, ResponseHeader{
+				Name:     h,
+				Variable: "upstream_http_" + strings.ReplaceAll(strings.ToLower(h), "-", "_"),
+			})
+		}
+	}
+
+	if config.Signin != "" && config.URL == "" {
+		return nil, errors.NewInvalidAnnotationsContentError(parser.GetAnnotationWithPrefix(authSigninAnnotation), config.Signin)
+	}
+
+	if config.Type == "" {
+		return config, nil
+	}
+
+	if config.Secret == "" {
+		msg := fmt.Sprintf("annotation %s requires %s, ingress name %s", authTypeAnnotation, authSecretAnnotation, ing.Name)
+		return nil, errors.NewNotSatisfiableError(msg)
+	}
+
+	if config.Realm == "" {
+		config.Realm = defaultRealm
+	}
+
+	secret, err := a.r.GetSecret(client.ObjectKey{Name: config.Secret, Namespace: ing.Namespace})
+	if err != nil {
+		klog.ErrorS(err, fmt.Sprintf("fail to get auth secret: %s, in namespace: %s", config.Secret, ing.Namespace))
+		return nil, errors.NewNotSatisfiableError(fmt.Sprintf("auth secret %s not found in namespace %s", config.Secret, ing.Namespace))
+	}
+
+	config.Htpasswd = secret.Data[htpasswdKey]
+	if len(config.Htpasswd) == 0 {
+		return nil, errors.NewInvalidAnnotationsContentError(parser.GetAnnotationWithPrefix(authSecretAnnotation), config.Secret)
+	}
+
+	return config, nil
+}
+
+func (a *auth) Validate(anns map[string]string) error {
+	return parser.CheckAnnotations(anns, authAnnotations.Annotations)
+}
+
+// validateURL accepts an absolute http or https url
+func validateURL(val string) error {
+	u, err := url.Parse(val)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || strings.ContainsAny(val, " ;{}\"'") {
+		return fmt.Errorf("%s is not a valid http or https url", val)
+	}
+
+	return nil
+}
@@ -0,0 +1,129 @@
+package auth
+
+import (
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations/testutil"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/errors"
+	corev1 "k8s.io/api/core/v1"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	htpasswd := []byte("user:$apr1$kdYQ6pLX$eCXmz5vPzHyUH2TUXbdBS/\n")
+	r := testutil.Resolver{Secrets: map[string]*corev1.Secret{
+		"basic-auth": {Data: map[string][]byte{htpasswdKey: htpasswd}},
+		"no-auth":    {Data: map[string][]byte{"users": htpasswd}},
+	}}
+
+	tests := []struct {
+		name    string
+		anns    map[string]string
+		want    *Config
+		wantErr bool
+	}{
+		{
+			name: "no authentication",
+			anns: map[string]string{},
+			want: &Config{},
+		},
+		{
+			name: "basic",
+			anns: map[string]string{authTypeAnnotation: "basic", authSecretAnnotation: "basic-auth"},
+			want: &Config{Type: "basic", Secret: "basic-auth", Realm: defaultRealm, Htpasswd: htpasswd},
+		},
+		{
+			name: "basic with a realm",
+			anns: map[string]string{authTypeAnnotation: "basic", authSecretAnnotation: "basic-auth", authRealmAnnotation: "Staff Only"},
+			want: &Config{Type: "basic", Secret: "basic-auth", Realm: "Staff Only", Htpasswd: htpasswd},
+		},
+		{
+			name: "external",
+			anns: map[string]string{
+				authURLAnnotation:             "http://auth.default.svc/verify",
+				authSigninAnnotation:          "https://auth.example.com/signin",
+				authResponseHeadersAnnotation: "X-User, X-Auth-Email",
+			},
+			want: &Config{
+				URL:    "http://auth.default.svc/verify",
+				Signin: "https://auth.example.com/signin",
+				ResponseHeaders: []ResponseHeader{
+					{Name: "X-User", Variable: "upstream_http_x_user"},
+					{Name: "X-Auth-Email", Variable: "upstream_http_x_auth_email"},
+				},
+			},
+		},
+		{
+			name:    "unknown type",
+			anns:    map[string]string{authTypeAnnotation: "digest", authSecretAnnotation: "basic-auth"},
+			wantErr: true,
+		},
+		{
+			name:    "type without a secret",
+			anns:    map[string]string{authTypeAnnotation: "basic"},
+			wantErr: true,
+		},
+		{
+			name:    "missing secret",
+			anns:    map[string]string{authTypeAnnotation: "basic", authSecretAnnotation: "missing"},
+			wantErr: true,
+		},
+		{
+			name:    "secret without htpasswd entries",
+			anns:    map[string]string{authTypeAnnotation: "basic", authSecretAnnotation: "no-auth"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid secret name",
+			anns:    map[string]string{authTypeAnnotation: "basic", authSecretAnnotation: "../basic-auth"},
+			wantErr: true,
+		},
+		{
+			name:    "realm breaks out of its quotes",
+			anns:    map[string]string{authTypeAnnotation: "basic", authSecretAnnotation: "basic-auth", authRealmAnnotation: `a"; allow all; "`},
+			wantErr: true,
+		},
+		{
+			name:    "relative url",
+			anns:    map[string]string{authURLAnnotation: "/verify"},
+			wantErr: true,
+		},
+		{
+			name:    "url with a directive",
+			anns:    map[string]string{authURLAnnotation: "http://auth.default.svc/verify;return 200"},
+			wantErr: true,
+		},
+		{
+			name:    "url of another scheme",
+			anns:    map[string]string{authURLAnnotation: "ftp://auth.default.svc/verify"},
+			wantErr: true,
+		},
+		{
+			name:    "signin without url",
+			anns:    map[string]string{authSigninAnnotation: "https://auth.example.com/signin"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid response header",
+			anns:    map[string]string{authURLAnnotation: "http://auth.default.svc/verify", authResponseHeadersAnnotation: "X-User;"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewParser(r).Parse(testutil.Ingress(tt.anns))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if !errors.IsInvalidAnnotationsContentError(err) && !errors.IsNotSatisfiableError(err) {
+					t.Errorf("Parse() error = %v, want an invalid or not satisfiable annotation error", err)
+				}
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package authtls
+
+import (
+	"fmt"
+	ingressv1 "github.com/Lxb921006/ingress-nginx-kubebuilder/api/v1"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations/parser"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations/resolver"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/errors"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	authTLSSecretAnnotation       = "auth-tls-secret"
+	authTLSVerifyClientAnnotation = "auth-tls-verify-client"
+	authTLSVerifyDepthAnnotation  = "auth-tls-verify-depth"
+	authTLSPassCertAnnotation     = "auth-tls-pass-certificate-to-upstream"
+	// caKey is the key of the secret holding the pem encoded ca bundle
+	caKey               = "ca.crt"
+	defaultVerifyClient = "on"
+	defaultVerifyDepth  = 1
+)
+
+var authTLSAnnotations = parser.Annotation{
+	Group: "authTLS",
+	Annotations: parser.AnnotationFields{
+		authTLSSecretAnnotation: {
+			Doc:       "secret of the namespace of the ingress holding the ca bundle the client certificates are verified with under the key `ca.crt`, the paths on a host rendered by an older ingress are only served when it verifies the same client certificates, e.g: `client-ca`, required",
+			Validator: parser.ValidateRegex(`^[a-z0-9]([-a-z0-9.]*[a-z0-9])?$`),
+		},
+		authTLSVerifyClientAnnotation: {
+			Doc:       "verification of the client certificates, `optional` lets the clients without one through, e.g: `on, off, optional or optional_no_ca`, optional, defaults to on",
+			Validator: parser.ValidateRegex(`^(on|off|optional|optional_no_ca)$`),
+		},
+		authTLSVerifyDepthAnnotation: {
+			Doc:       "depth of the chain of the client certificates, e.g: `2`, optional, defaults to 1",
+			Validator: parser.ValidateIntRange(1, 10),
+		},
+		authTLSPassCertAnnotation: {
+			Doc: "pass the client certificate to the backend in the `ssl-client-cert` header, e.g: `true or false`, optional",
+		},
+	},
+}
+
+// Config verifies the certificates of the clients of the hosts of the ingress against a ca bundle
+type Config struct {
+	Secret string `json:"secret"`
+	// CAFile is the ca bundle written by the controller from CACert
+	CAFile          string `json:"ca-file"`
+	CACert          []byte `json:"-"`
+	VerifyClient    string `json:"verify-client"`
+	VerifyDepth     int    `json:"verify-depth"`
+	PassCertificate bool   `json:"pass-certificate"`
+}
+
+type authTLS struct {
+	r resolver.Resolver
+}
+
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return &authTLS{
+		r: r,
+	}
+}
+
+func (a *authTLS) Parse(ing *ingressv1.Ingress) (interface{}, error) {
+	var err error
+	config := &Config{
+		VerifyClient: defaultVerifyClient,
+		VerifyDepth:  defaultVerifyDepth,
+	}
+
+	config.Secret, err = parser.GetStringAnnotation(authTLSSecretAnnotation, ing, authTLSAnnotations.Annotations)
+	if err != nil {
+		if errors.IsInvalidAnnotationsContentError(err) {
+			return nil, err
+		}
+		return config, nil
+	}
+
+	verify, err := parser.GetStringAnnotation(authTLSVerifyClientAnnotation, ing, authTLSAnnotations.Annotations)
+	if err != nil {
+		if errors.IsInvalidAnnotationsContentError(err) {
+			return nil, err
+		}
+	} else {
+		config.VerifyClient = verify
+	}
+
+	depth, err := parser.GetIntAnnotation(authTLSVerifyDepthAnnotation, ing, authTLSAnnotations.Annotations)
+	if err != nil {
+		if errors.IsInvalidAnnotationsContentError(err) {
+			return nil, err
+		}
+	} else {
+		config.VerifyDepth = depth
+	}
+
+	config.PassCertificate, _ = parser.GetBoolAnnotations(authTLSPassCertAnnotation, ing, authTLSAnnotations.Annotations)
+
+	if len(ing.Spec.TLS) == 0 {
+		msg := fmt.Sprintf("annotation %s requires the tls of the ingress, ingress name %s", authTLSSecretAnnotation, ing.Name)
+		return nil, errors.NewNotSatisfiableError(msg)
+	}
+
+	secret, err := a.r.GetSecret(client.ObjectKey{Name: config.Secret, Namespace: ing.Namespace})
+	if err != nil {
+		klog.ErrorS(err, fmt.Sprintf("fail to get auth tls secret: %s, in namespace: %s", config.Secret, ing.Namespace))
+		return nil, errors.NewNotSatisfiableError(fmt.Sprintf("auth tls secret %s not found in namespace %s", config.Secret, ing.Namespace))
+	}
+
+	config.CACert = secret.Data[caKey]
+	if !parser.IsCABundle(config.CACert) {
+		return nil, errors.NewInvalidAnnotationsContentError(parser.GetAnnotationWithPrefix(authTLSSecretAnnotation), config.Secret)
+	}
+
+	return config, nil
+}
+
+func (a *authTLS) Validate(anns map[string]string) error {
+	return parser.CheckAnnotations(anns, authTLSAnnotations.Annotations)
+}
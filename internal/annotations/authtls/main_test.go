@@ -0,0 +1,107 @@
+package authtls
+
+import (
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations/testutil"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/errors"
+	corev1 "k8s.io/api/core/v1"
+	netv1 "k8s.io/api/networking/v1"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	ca := testutil.CACert()
+	r := testutil.Resolver{Secrets: map[string]*corev1.Secret{
+		"client-ca": {Data: map[string][]byte{caKey: ca}},
+		"no-ca":     {Data: map[string][]byte{"tls.crt": ca}},
+		"not-pem":   {Data: map[string][]byte{caKey: []byte("not a certificate")}},
+	}}
+
+	tests := []struct {
+		name    string
+		anns    map[string]string
+		noTLS   bool
+		want    *Config
+		wantErr bool
+	}{
+		{
+			name: "no client certificates",
+			anns: map[string]string{},
+			want: &Config{VerifyClient: defaultVerifyClient, VerifyDepth: defaultVerifyDepth},
+		},
+		{
+			name: "defaults",
+			anns: map[string]string{authTLSSecretAnnotation: "client-ca"},
+			want: &Config{Secret: "client-ca", CACert: ca, VerifyClient: defaultVerifyClient, VerifyDepth: defaultVerifyDepth},
+		},
+		{
+			name: "settings",
+			anns: map[string]string{
+				authTLSSecretAnnotation:       "client-ca",
+				authTLSVerifyClientAnnotation: "optional",
+				authTLSVerifyDepthAnnotation:  "2",
+				authTLSPassCertAnnotation:     "true",
+			},
+			want: &Config{Secret: "client-ca", CACert: ca, VerifyClient: "optional", VerifyDepth: 2, PassCertificate: true},
+		},
+		{
+			name:    "without tls",
+			anns:    map[string]string{authTLSSecretAnnotation: "client-ca"},
+			noTLS:   true,
+			wantErr: true,
+		},
+		{
+			name:    "missing secret",
+			anns:    map[string]string{authTLSSecretAnnotation: "missing"},
+			wantErr: true,
+		},
+		{
+			name:    "secret without ca bundle",
+			anns:    map[string]string{authTLSSecretAnnotation: "no-ca"},
+			wantErr: true,
+		},
+		{
+			name:    "ca bundle not pem",
+			anns:    map[string]string{authTLSSecretAnnotation: "not-pem"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid secret name",
+			anns:    map[string]string{authTLSSecretAnnotation: "Client_CA"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown verification",
+			anns:    map[string]string{authTLSSecretAnnotation: "client-ca", authTLSVerifyClientAnnotation: "required"},
+			wantErr: true,
+		},
+		{
+			name:    "depth out of range",
+			anns:    map[string]string{authTLSSecretAnnotation: "client-ca", authTLSVerifyDepthAnnotation: "0"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ing := testutil.Ingress(tt.anns)
+			if !tt.noTLS {
+				ing.Spec.TLS = []netv1.IngressTLS{{Hosts: []string{"a.com"}, SecretName: "a-tls"}}
+			}
+
+			got, err := NewParser(r).Parse(ing)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if !errors.IsInvalidAnnotationsContentError(err) && !errors.IsNotSatisfiableError(err) {
+					t.Errorf("Parse() error = %v, want an invalid or not satisfiable annotation error", err)
+				}
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package backendprotocol
+
+import (
+	"fmt"
+	ingressv1 "github.com/Lxb921006/ingress-nginx-kubebuilder/api/v1"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations/parser"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations/resolver"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/errors"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"strings"
+)
+
+const (
+	backendProtocolAnnotation     = "backend-protocol"
+	proxySSLSecretAnnotation      = "proxy-ssl-secret"
+	proxySSLNameAnnotation        = "proxy-ssl-name"
+	proxySSLVerifyDepthAnnotation = "proxy-ssl-verify-depth"
+	// caKey is the key of the secret holding the pem encoded ca bundle
+	caKey              = "ca.crt"
+	defaultProtocol    = "HTTP"
+	defaultVerifyDepth = 1
+)
+
+var backendProtocolAnnotations = parser.Annotation{
+	Group: "backendProtocol",
+	Annotations: parser.AnnotationFields{
+		backendProtocolAnnotation: {
+			Doc:       "protocol the backends are spoken to, `H2C` is http2 without tls, e.g: `HTTP, HTTPS, GRPC, GRPCS or H2C`, optional, defaults to HTTP",
+			Validator: parser.ValidateRegex(`^(?i)(HTTP|HTTPS|GRPC|GRPCS|H2C)$`),
+		},
+		proxySSLSecretAnnotation: {
+			Doc:       "secret of the namespace of the ingress holding the ca bundle the certificates of the HTTPS or GRPCS backends are verified with under the key `ca.crt`, e.g: `backend-ca`, optional",
+			Validator: parser.ValidateRegex(`^[a-z0-9]([-a-z0-9.]*[a-z0-9])?$`),
+		},
+		proxySSLNameAnnotation: {
+			Doc:       "name the certificates of the backends are verified against and sent as sni, e.g: `api.example.com`, optional, defaults to the dns name of the service, e.g: `api.default.svc`",
+			Validator: parser.ValidateRegex(`^[A-Za-z0-9.-]+$`),
+		},
+		proxySSLVerifyDepthAnnotation: {
+			Doc:       "depth of the chain of the certificates of the backends, e.g: `2`, optional, defaults to 1",
+			Validator: parser.ValidateIntRange(1, 10),
+		},
+	},
+}
+
+// Config switches the locations of the ingress to the protocol of its backends
+type Config struct {
+	Protocol string `json:"protocol"`
+	Secret   string `json:"secret"`
+	// CAFile is the ca bundle written by the controller from CACert
+	CAFile      string `json:"ca-file"`
+	CACert      []byte `json:"-"`
+	SSLName     string `json:"ssl-name"`
+	VerifyDepth int    `json:"verify-depth"`
+}
+
+type backendProtocol struct {
+	r resolver.Resolver
+}
+
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return &backendProtocol{
+		r: r,
+	}
+}
+
+func (b *backendProtocol) Parse(ing *ingressv1.Ingress) (interface{}, error) {
+	config := &Config{
+		Protocol:    defaultProtocol,
+		VerifyDepth: defaultVerifyDepth,
+	}
+
+	for name, field := range map[string]*string{
+		backendProtocolAnnotation: &config.Protocol,
+		proxySSLSecretAnnotation:  &config.Secret,
+		proxySSLNameAnnotation:    &config.SSLName,
+	} {
+		val, err := parser.GetStringAnnotation(name, ing, backendProtocolAnnotations.Annotations)
+		if err != nil {
+			if errors.IsInvalidAnnotationsContentError(err) {
+				return nil, err
+			}
+			continue
+		}
+		*field = val
+	}
+	config.Protocol = strings.ToUpper(config.Protocol)
+
+	depth, err := parser.GetIntAnnotation(proxySSLVerifyDepthAnnotation, ing, backendProtocolAnnotations.Annotations)
+	if err != nil {
+		if errors.IsInvalidAnnotationsContentError(err) {
+			return nil, err
+		}
+	} else {
+		config.VerifyDepth = depth
+	}
+
+	if config.Secret == "" {
+		return config, nil
+	}
+
+	if !config.TLS() {
+		msg := fmt.Sprintf("annotation %s requires %s HTTPS or GRPCS, ingress name %s", proxySSLSecretAnnotation, backendProtocolAnnotation, ing.Name)
+		return nil, errors.NewNotSatisfiableError(msg)
+	}
+
+	secret, err := b.r.GetSecret(client.ObjectKey{Name: config.Secret, Namespace: ing.Namespace})
+	if err != nil {
+		klog.ErrorS(err, fmt.Sprintf("fail to get proxy ssl secret: %s, in namespace: %s", config.Secret, ing.Namespace))
+		return nil, errors.NewNotSatisfiableError(fmt.Sprintf("proxy ssl secret %s not found in namespace %s", config.Secret, ing.Namespace))
+	}
+
+	config.CACert = secret.Data[caKey]
+	if !parser.IsCABundle(config.CACert) {
+		return nil, errors.NewInvalidAnnotationsContentError(parser.GetAnnotationWithPrefix(proxySSLSecretAnnotation), config.Secret)
+	}
+
+	return config, nil
+}
+
+func (b *backendProtocol) Validate(anns map[string]string) error {
+	return parser.CheckAnnotations(anns, backendProtocolAnnotations.Annotations)
+}
+
+// GRPC reports whether the backends are passed to by the grpc module, H2C included since proxy_pass only speaks http/1
+func (c Config) GRPC() bool {
+	return c.Protocol == "GRPC" || c.Protocol == "GRPCS" || c.Protocol == "H2C"
+}
+
+// TLS reports whether the connections to the backends are encrypted
+func (c Config) TLS() bool {
+	return c.Protocol == "HTTPS" || c.Protocol == "GRPCS"
+}
+
+// Module returns the prefix of the directives of the location, e.g: proxy_pass or grpc_pass
+func (c Config) Module() string {
+	if c.GRPC() {
+		return "grpc"
+	}
+
+	return "proxy"
+}
+
+// Scheme returns the scheme of the pass directive of the location
+func (c Config) Scheme() string {
+	switch c.Protocol {
+	case "HTTPS":
+		return "https"
+	case "GRPC", "H2C":
+		return "grpc"
+	case "GRPCS":
+		return "grpcs"
+	}
+
+	return "http"
+}
@@ -0,0 +1,115 @@
+package backendprotocol
+
+import (
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations/testutil"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/errors"
+	corev1 "k8s.io/api/core/v1"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	ca := testutil.CACert()
+	r := testutil.Resolver{Secrets: map[string]*corev1.Secret{
+		"backend-ca": {Data: map[string][]byte{caKey: ca}},
+		"no-ca":      {Data: map[string][]byte{"tls.crt": ca}},
+	}}
+
+	tests := []struct {
+		name       string
+		anns       map[string]string
+		want       *Config
+		wantModule string
+		wantScheme string
+		wantErr    bool
+	}{
+		{
+			name:       "http",
+			anns:       map[string]string{},
+			want:       &Config{Protocol: "HTTP", VerifyDepth: defaultVerifyDepth},
+			wantModule: "proxy",
+			wantScheme: "http",
+		},
+		{
+			name:       "https verified by a ca bundle",
+			anns:       map[string]string{backendProtocolAnnotation: "https", proxySSLSecretAnnotation: "backend-ca", proxySSLNameAnnotation: "api.example.com", proxySSLVerifyDepthAnnotation: "2"},
+			want:       &Config{Protocol: "HTTPS", Secret: "backend-ca", CACert: ca, SSLName: "api.example.com", VerifyDepth: 2},
+			wantModule: "proxy",
+			wantScheme: "https",
+		},
+		{
+			name:       "grpc",
+			anns:       map[string]string{backendProtocolAnnotation: "GRPC"},
+			want:       &Config{Protocol: "GRPC", VerifyDepth: defaultVerifyDepth},
+			wantModule: "grpc",
+			wantScheme: "grpc",
+		},
+		{
+			name:       "grpcs",
+			anns:       map[string]string{backendProtocolAnnotation: "GRPCS"},
+			want:       &Config{Protocol: "GRPCS", VerifyDepth: defaultVerifyDepth},
+			wantModule: "grpc",
+			wantScheme: "grpcs",
+		},
+		{
+			name:       "h2c",
+			anns:       map[string]string{backendProtocolAnnotation: "h2c"},
+			want:       &Config{Protocol: "H2C", VerifyDepth: defaultVerifyDepth},
+			wantModule: "grpc",
+			wantScheme: "grpc",
+		},
+		{
+			name:    "unknown protocol",
+			anns:    map[string]string{backendProtocolAnnotation: "SPDY"},
+			wantErr: true,
+		},
+		{
+			name:    "ca bundle without tls",
+			anns:    map[string]string{backendProtocolAnnotation: "HTTP", proxySSLSecretAnnotation: "backend-ca"},
+			wantErr: true,
+		},
+		{
+			name:    "missing secret",
+			anns:    map[string]string{backendProtocolAnnotation: "HTTPS", proxySSLSecretAnnotation: "missing"},
+			wantErr: true,
+		},
+		{
+			name:    "secret without ca bundle",
+			anns:    map[string]string{backendProtocolAnnotation: "HTTPS", proxySSLSecretAnnotation: "no-ca"},
+			wantErr: true,
+		},
+		{
+			name:    "ssl name with a directive",
+			anns:    map[string]string{backendProtocolAnnotation: "HTTPS", proxySSLNameAnnotation: "api.example.com; proxy_ssl_verify off"},
+			wantErr: true,
+		},
+		{
+			name:    "depth out of range",
+			anns:    map[string]string{backendProtocolAnnotation: "HTTPS", proxySSLVerifyDepthAnnotation: "11"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewParser(r).Parse(testutil.Ingress(tt.anns))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if !errors.IsInvalidAnnotationsContentError(err) && !errors.IsNotSatisfiableError(err) {
+					t.Errorf("Parse() error = %v, want an invalid or not satisfiable annotation error", err)
+				}
+				return
+			}
+
+			c := got.(*Config)
+			if !reflect.DeepEqual(c, tt.want) {
+				t.Errorf("Parse() = %+v, want %+v", c, tt.want)
+			}
+			if c.Module() != tt.wantModule || c.Scheme() != tt.wantScheme {
+				t.Errorf("Module(), Scheme() = %s, %s, want %s, %s", c.Module(), c.Scheme(), tt.wantModule, tt.wantScheme)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package canary
+
+import (
+	"fmt"
+	ingressv1 "github.com/Lxb921006/ingress-nginx-kubebuilder/api/v1"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations/parser"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations/resolver"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/errors"
+	"k8s.io/klog/v2"
+	"strings"
+)
+
+const (
+	canaryAnnotation            = "canary"
+	canaryByHeaderAnnotation    = "canary-by-header"
+	canaryByHeaderValAnnotation = "canary-by-header-value"
+	canaryByCookieAnnotation    = "canary-by-cookie"
+	canaryWeightAnnotation      = "canary-weight"
+	useWeightAnnotation         = "use-weight"
+)
+
+var canaryAnnotations = parser.Annotation{
+	Group: "canary",
+	Annotations: parser.AnnotationFields{
+		canaryAnnotation: {
+			Doc: "the paths of the ingress are the canary of the ingress declaring the same host and path, e.g: `true or false`, required",
+		},
+		canaryByHeaderAnnotation: {
+			Doc:       "requests with the header set to `always` go to the canary, `never` to the primary ingress, e.g: `X-Canary`, optional",
+			Validator: parser.ValidateRegex(`^[A-Za-z0-9-]+$`),
+		},
+		canaryByHeaderValAnnotation: {
+			Doc:       "requests with canary-by-header set to this value go to the canary instead of `always`, e.g: `v2`, optional",
+			Validator: parser.ValidateRegex(`^[^\s"'{};\\]+$`),
+		},
+		canaryByCookieAnnotation: {
+			Doc:       "requests with the cookie set to `always` go to the canary, `never` to the primary ingress, e.g: `canary`, optional",
+			Validator: parser.ValidateRegex(`^[A-Za-z0-9_]+$`),
+		},
+		canaryWeightAnnotation: {
+			Doc:       "percentage of the other requests sent to the canary, e.g: `20`, optional, defaults to 0",
+			Validator: parser.ValidateIntRange(0, 100),
+		},
+	},
+}
+
+// Config routes the requests of a host and path between the primary ingress and the canary ingress.
+// The header is checked first, then the cookie, the remaining requests are split by weight.
+type Config struct {
+	Enabled     bool   `json:"canary"`
+	Header      string `json:"header"`
+	HeaderValue string `json:"header-value"`
+	Cookie      string `json:"cookie"`
+	Weight      int    `json:"weight"`
+}
+
+type canary struct {
+	r resolver.Resolver
+}
+
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return &canary{}
+}
+
+// Parse The other canary annotations are ignored unless canary is true
+func (c *canary) Parse(ing *ingressv1.Ingress) (interface{}, error) {
+	var err error
+	config := &Config{}
+
+	config.Enabled, err = parser.GetBoolAnnotations(canaryAnnotation, ing, canaryAnnotations.Annotations)
+	if err != nil || !config.Enabled {
+		return config, nil
+	}
+
+	useWeight, _ := parser.GetBoolAnnotations(useWeightAnnotation, ing, canaryAnnotations.Annotations)
+	if useWeight {
+		msg := fmt.Sprintf("annotation %s can not be used together with %s, ingress name %s", canaryAnnotation, useWeightAnnotation, ing.Name)
+		return nil, errors.NewNotSatisfiableError(msg)
+	}
+
+	for name, field := range map[string]*string{
+		canaryByHeaderAnnotation:    &config.Header,
+		canaryByHeaderValAnnotation: &config.HeaderValue,
+		canaryByCookieAnnotation:    &config.Cookie,
+	} {
+		val, err := parser.GetStringAnnotation(name, ing, canaryAnnotations.Annotations)
+		if err != nil {
+			if errors.IsInvalidAnnotationsContentError(err) {
+				return nil, err
+			}
+			continue
+		}
+		*field = val
+	}
+
+	config.Weight, err = parser.GetIntAnnotation(canaryWeightAnnotation, ing, canaryAnnotations.Annotations)
+	if err != nil {
+		if errors.IsInvalidAnnotationsContentError(err) {
+			return nil, err
+		}
+		klog.V(5).Infof("%s is not set, defaulting to 0", canaryWeightAnnotation)
+	}
+
+	if config.HeaderValue != "" && config.Header == "" {
+		return nil, errors.NewInvalidAnnotationsContentError(canaryByHeaderValAnnotation, config.HeaderValue)
+	}
+
+	return config, nil
+}
+
+func (c *canary) Validate(anns map[string]string) error {
+	return parser.CheckAnnotations(anns, canaryAnnotations.Annotations)
+}
+
+// HeaderVariable returns the nginx variable of the header, e.g: X-Canary is $http_x_canary
+func (c Config) HeaderVariable() string {
+	return "http_" + strings.ReplaceAll(strings.ToLower(c.Header), "-", "_")
+}
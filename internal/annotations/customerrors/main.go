@@ -0,0 +1,121 @@
+package customerrors
+
+import (
+	"fmt"
+	ingressv1 "github.com/Lxb921006/ingress-nginx-kubebuilder/api/v1"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations/parser"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations/resolver"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog/v2"
+	"strconv"
+	"strings"
+)
+
+const (
+	customHTTPErrorsAnnotation = "custom-http-errors"
+	defaultBackendAnnotation   = "default-backend"
+)
+
+var customErrorsAnnotations = parser.Annotation{
+	Group: "customErrors",
+	Annotations: parser.AnnotationFields{
+		customHTTPErrorsAnnotation: {
+			Doc:       "status codes of the backends replaced by the response of default-backend, e.g: `404,503`, optional",
+			Validator: validateCodes,
+		},
+		defaultBackendAnnotation: {
+			Doc:       "service of the namespace of the ingress serving the custom errors, optionally followed by its port, e.g: `error-pages` or `error-pages:8080`, optional, defaults to spec.defaultBackend",
+			Validator: parser.ValidateRegex(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(:\d+)?$`),
+		},
+	},
+}
+
+// Config sends the responses of the backends with one of Codes to the error service instead. The error
+// service gets the code and the request in the X-Code, X-Original-URI, X-Namespace and X-Ingress-Name headers.
+type Config struct {
+	Codes   []int  `json:"codes"`
+	Service string `json:"service"`
+	// Address is the dns name and port of the error service
+	Address string `json:"address"`
+}
+
+type customErrors struct {
+	r resolver.Resolver
+}
+
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return &customErrors{
+		r: r,
+	}
+}
+
+func (c *customErrors) Parse(ing *ingressv1.Ingress) (interface{}, error) {
+	config := &Config{}
+
+	codes, err := parser.GetStringAnnotation(customHTTPErrorsAnnotation, ing, customErrorsAnnotations.Annotations)
+	if err != nil {
+		if errors.IsInvalidAnnotationsContentError(err) {
+			return nil, err
+		}
+		return config, nil
+	}
+
+	unique := sets.New[int]()
+	for _, code := range strings.Split(codes, ",") {
+		if i, err := strconv.Atoi(strings.TrimSpace(code)); err == nil {
+			unique.Insert(i)
+		}
+	}
+	config.Codes = sets.List(unique)
+
+	backend, err := parser.GetStringAnnotation(defaultBackendAnnotation, ing, customErrorsAnnotations.Annotations)
+	if err != nil && errors.IsInvalidAnnotationsContentError(err) {
+		return nil, err
+	}
+
+	var svc *corev1.Service
+	name, port, _ := strings.Cut(backend, ":")
+	switch {
+	case name != "":
+		svc, err = c.r.GetService(name)
+	case ing.Spec.DefaultBackend != nil && ing.Spec.DefaultBackend.Service != nil:
+		svc, err = c.r.GetDefaultService()
+	default:
+		msg := fmt.Sprintf("annotation %s requires %s or spec.defaultBackend, ingress name %s", customHTTPErrorsAnnotation, defaultBackendAnnotation, ing.Name)
+		return nil, errors.NewNotSatisfiableError(msg)
+	}
+	if err != nil {
+		klog.ErrorS(err, fmt.Sprintf("fail to get the error service of ingress: %s, namespace: %s", ing.Name, ing.Namespace))
+		return nil, errors.NewNotSatisfiableError(fmt.Sprintf("error service %s not found in namespace %s", name, ing.Namespace))
+	}
+
+	if port == "" {
+		if len(svc.Spec.Ports) == 0 {
+			return nil, errors.NewInvalidAnnotationsContentError(parser.GetAnnotationWithPrefix(defaultBackendAnnotation), backend)
+		}
+		port = strconv.Itoa(int(svc.Spec.Ports[0].Port))
+	}
+
+	config.Service = svc.Name
+	config.Address = fmt.Sprintf("%s.%s.svc:%s", svc.Name, ing.Namespace, port)
+
+	return config, nil
+}
+
+func (c *customErrors) Validate(anns map[string]string) error {
+	return parser.CheckAnnotations(anns, customErrorsAnnotations.Annotations)
+}
+
+// validateCodes accepts a list of the status codes nginx can intercept
+func validateCodes(val string) error {
+	for _, code := range strings.Split(val, ",") {
+		i, err := strconv.Atoi(strings.TrimSpace(code))
+		if err != nil || i < 300 || i > 599 {
+			return fmt.Errorf("%s is not a valid error code", code)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,118 @@
+package customerrors
+
+import (
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations/testutil"
+	corev1 "k8s.io/api/core/v1"
+	netv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	service := func(name string, ports ...int32) *corev1.Service {
+		svc := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"}}
+		for _, port := range ports {
+			svc.Spec.Ports = append(svc.Spec.Ports, corev1.ServicePort{Port: port})
+		}
+		return svc
+	}
+	r := testutil.Resolver{
+		Services: map[string]*corev1.Service{
+			"error-pages":  service("error-pages", 8080, 9090),
+			"default-http": service("default-http", 80),
+			"no-ports":     service("no-ports"),
+		},
+		DefaultService: "default-http",
+	}
+
+	tests := []struct {
+		name           string
+		anns           map[string]string
+		defaultBackend bool
+		want           *Config
+		wantErr        bool
+	}{
+		{
+			name: "no custom errors",
+			anns: map[string]string{defaultBackendAnnotation: "error-pages"},
+			want: &Config{},
+		},
+		{
+			name: "first port of the error service",
+			anns: map[string]string{customHTTPErrorsAnnotation: "503, 404,404", defaultBackendAnnotation: "error-pages"},
+			want: &Config{Codes: []int{404, 503}, Service: "error-pages", Address: "error-pages.default.svc:8080"},
+		},
+		{
+			name: "port of the error service",
+			anns: map[string]string{customHTTPErrorsAnnotation: "404", defaultBackendAnnotation: "error-pages:9090"},
+			want: &Config{Codes: []int{404}, Service: "error-pages", Address: "error-pages.default.svc:9090"},
+		},
+		{
+			name:           "spec default backend",
+			anns:           map[string]string{customHTTPErrorsAnnotation: "500"},
+			defaultBackend: true,
+			want:           &Config{Codes: []int{500}, Service: "default-http", Address: "default-http.default.svc:80"},
+		},
+		{
+			name:    "no error service",
+			anns:    map[string]string{customHTTPErrorsAnnotation: "404"},
+			wantErr: true,
+		},
+		{
+			name:    "missing error service",
+			anns:    map[string]string{customHTTPErrorsAnnotation: "404", defaultBackendAnnotation: "missing"},
+			wantErr: true,
+		},
+		{
+			name:    "error service without ports",
+			anns:    map[string]string{customHTTPErrorsAnnotation: "404", defaultBackendAnnotation: "no-ports"},
+			wantErr: true,
+		},
+		{
+			name:    "code not intercepted",
+			anns:    map[string]string{customHTTPErrorsAnnotation: "404,200", defaultBackendAnnotation: "error-pages"},
+			wantErr: true,
+		},
+		{
+			name:    "code not a number",
+			anns:    map[string]string{customHTTPErrorsAnnotation: "404,5xx", defaultBackendAnnotation: "error-pages"},
+			wantErr: true,
+		},
+		{
+			name:    "empty code",
+			anns:    map[string]string{customHTTPErrorsAnnotation: "404,", defaultBackendAnnotation: "error-pages"},
+			wantErr: true,
+		},
+		{
+			name:    "error service of another namespace",
+			anns:    map[string]string{customHTTPErrorsAnnotation: "404", defaultBackendAnnotation: "other/error-pages"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid port",
+			anns:    map[string]string{customHTTPErrorsAnnotation: "404", defaultBackendAnnotation: "error-pages:http"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ing := testutil.Ingress(tt.anns)
+			if tt.defaultBackend {
+				ing.Spec.DefaultBackend = &netv1.IngressBackend{Service: &netv1.IngressServiceBackend{Name: "default-http"}}
+			}
+
+			got, err := NewParser(r).Parse(ing)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
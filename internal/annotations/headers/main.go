@@ -0,0 +1,169 @@
+package headers
+
+import (
+	"fmt"
+	ingressv1 "github.com/Lxb921006/ingress-nginx-kubebuilder/api/v1"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations/parser"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations/resolver"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/config"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	setRequestHeadersAnnotation    = "set-request-headers"
+	removeRequestHeadersAnnotation = "remove-request-headers"
+	addResponseHeadersAnnotation   = "add-response-headers"
+	hideResponseHeadersAnnotation  = "hide-response-headers"
+)
+
+var headersAnnotations = parser.Annotation{
+	Group: "headers",
+	Annotations: parser.AnnotationFields{
+		setRequestHeadersAnnotation: {
+			Doc:       "headers set on the requests to the backends, one `Name: value` per line, the values may use nginx variables, e.g: `X-Env: prod\\nX-Client: $remote_addr`, optional",
+			Validator: validateRequestHeaders,
+		},
+		removeRequestHeadersAnnotation: {
+			Doc:       "headers of the clients not passed to the backends, e.g: `X-Debug, Cookie`, optional",
+			Validator: validateRequestNames,
+		},
+		addResponseHeadersAnnotation: {
+			Doc:       "headers added to the responses, including the errors, one `Name: value` per line, e.g: `X-Frame-Options: DENY`, optional",
+			Validator: validateResponseHeaders,
+		},
+		hideResponseHeadersAnnotation: {
+			Doc:       "headers of the responses of the backends not passed to the clients, e.g: `X-Powered-By, Server`, optional",
+			Validator: validateResponseNames,
+		},
+	},
+}
+
+// Config changes the headers of the requests to and the responses of the backends of the ingress
+type Config struct {
+	SetRequest    map[string]string `json:"set-request"`
+	RemoveRequest []string          `json:"remove-request"`
+	AddResponse   map[string]string `json:"add-response"`
+	HideResponse  []string          `json:"hide-response"`
+}
+
+// Headers are the headers rendered into a location
+type Headers struct {
+	Request  map[string]string
+	Remove   []string
+	Response map[string]string
+	Hide     []string
+}
+
+type headers struct {
+	r resolver.Resolver
+}
+
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return &headers{}
+}
+
+func (h *headers) Parse(ing *ingressv1.Ingress) (interface{}, error) {
+	config := &Config{}
+
+	for name, field := range map[string]*map[string]string{
+		setRequestHeadersAnnotation:  &config.SetRequest,
+		addResponseHeadersAnnotation: &config.AddResponse,
+	} {
+		val, err := parser.GetStringAnnotation(name, ing, headersAnnotations.Annotations)
+		if err != nil {
+			if errors.IsInvalidAnnotationsContentError(err) {
+				return nil, err
+			}
+			continue
+		}
+		*field, _ = parser.ParseHeaderLines(val)
+	}
+
+	for name, field := range map[string]*[]string{
+		removeRequestHeadersAnnotation: &config.RemoveRequest,
+		hideResponseHeadersAnnotation:  &config.HideResponse,
+	} {
+		val, err := parser.GetStringAnnotation(name, ing, headersAnnotations.Annotations)
+		if err != nil {
+			if errors.IsInvalidAnnotationsContentError(err) {
+				return nil, err
+			}
+			continue
+		}
+		*field, _ = parser.ParseHeaderNames(val)
+	}
+
+	return config, nil
+}
+
+func (h *headers) Validate(anns map[string]string) error {
+	return parser.CheckAnnotations(anns, headersAnnotations.Annotations)
+}
+
+// Merge returns the headers of the locations of the ingress, the headers of the ingress replace the ones of g
+// with the same name and the removed request headers are not set
+func (c Config) Merge(g *config.Global) Headers {
+	h := Headers{
+		Request:  make(map[string]string),
+		Remove:   c.RemoveRequest,
+		Response: make(map[string]string),
+		Hide:     c.HideResponse,
+	}
+
+	for _, set := range []map[string]string{g.RequestHeaders, c.SetRequest} {
+		for name, val := range set {
+			h.Request[name] = val
+		}
+	}
+	for _, name := range c.RemoveRequest {
+		delete(h.Request, name)
+	}
+
+	for _, set := range []map[string]string{g.ResponseHeaders, c.AddResponse} {
+		for name, val := range set {
+			h.Response[name] = val
+		}
+	}
+
+	return h
+}
+
+func validateRequestHeaders(val string) error {
+	headers, err := parser.ParseHeaderLines(val)
+	if err != nil {
+		return err
+	}
+
+	return checkControllerHeaders(sets.KeySet(headers))
+}
+
+func validateRequestNames(val string) error {
+	names, err := parser.ParseHeaderNames(val)
+	if err != nil {
+		return err
+	}
+
+	return checkControllerHeaders(sets.New(names...))
+}
+
+func validateResponseHeaders(val string) error {
+	_, err := parser.ParseHeaderLines(val)
+	return err
+}
+
+func validateResponseNames(val string) error {
+	_, err := parser.ParseHeaderNames(val)
+	return err
+}
+
+// checkControllerHeaders rejects the request headers the controller sets itself
+func checkControllerHeaders(names sets.Set[string]) error {
+	for name := range names {
+		if parser.IsControllerHeader(name) {
+			return fmt.Errorf("header %s is set by the controller", name)
+		}
+	}
+
+	return nil
+}
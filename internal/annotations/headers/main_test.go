@@ -0,0 +1,111 @@
+package headers
+
+import (
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations/testutil"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/config"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		anns    map[string]string
+		want    *Config
+		wantErr bool
+	}{
+		{
+			name: "no headers",
+			anns: map[string]string{},
+			want: &Config{},
+		},
+		{
+			name: "headers",
+			anns: map[string]string{
+				setRequestHeadersAnnotation:    "x-env: prod\n\nX-Client: $remote_addr\n",
+				removeRequestHeadersAnnotation: "x-debug, Cookie",
+				addResponseHeadersAnnotation:   "X-Frame-Options: DENY",
+				hideResponseHeadersAnnotation:  "Server,X-Powered-By,server",
+			},
+			want: &Config{
+				SetRequest:    map[string]string{"X-Env": "prod", "X-Client": "$remote_addr"},
+				RemoveRequest: []string{"Cookie", "X-Debug"},
+				AddResponse:   map[string]string{"X-Frame-Options": "DENY"},
+				HideResponse:  []string{"Server", "X-Powered-By"},
+			},
+		},
+		{
+			name: "controller header on the responses",
+			anns: map[string]string{addResponseHeadersAnnotation: "X-Forwarded-Proto: https"},
+			want: &Config{AddResponse: map[string]string{"X-Forwarded-Proto": "https"}},
+		},
+		{
+			name:    "controller header set on the requests",
+			anns:    map[string]string{setRequestHeadersAnnotation: "x-forwarded-for: 1.1.1.1"},
+			wantErr: true,
+		},
+		{
+			name:    "controller header removed from the requests",
+			anns:    map[string]string{removeRequestHeadersAnnotation: "Upgrade"},
+			wantErr: true,
+		},
+		{
+			name:    "line without a value",
+			anns:    map[string]string{setRequestHeadersAnnotation: "X-Env"},
+			wantErr: true,
+		},
+		{
+			name:    "value breaks out of its quotes",
+			anns:    map[string]string{addResponseHeadersAnnotation: `X-Env: prod"; return 200 "`},
+			wantErr: true,
+		},
+		{
+			name:    "invalid name",
+			anns:    map[string]string{hideResponseHeadersAnnotation: "Server;"},
+			wantErr: true,
+		},
+		{
+			name:    "empty name",
+			anns:    map[string]string{removeRequestHeadersAnnotation: "X-Debug,,Cookie"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewParser(nil).Parse(testutil.Ingress(tt.anns))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMerge(t *testing.T) {
+	g := &config.Global{
+		RequestHeaders:  map[string]string{"X-Env": "global", "Cookie": "a=b"},
+		ResponseHeaders: map[string]string{"X-Frame-Options": "SAMEORIGIN"},
+	}
+	c := Config{
+		SetRequest:    map[string]string{"X-Env": "prod"},
+		RemoveRequest: []string{"Cookie"},
+		AddResponse:   map[string]string{"X-Frame-Options": "DENY", "X-Server": "edge"},
+		HideResponse:  []string{"Server"},
+	}
+
+	want := Headers{
+		Request:  map[string]string{"X-Env": "prod"},
+		Remove:   []string{"Cookie"},
+		Response: map[string]string{"X-Frame-Options": "DENY", "X-Server": "edge"},
+		Hide:     []string{"Server"},
+	}
+	if got := c.Merge(g); !reflect.DeepEqual(got, want) {
+		t.Errorf("Merge() = %+v, want %+v", got, want)
+	}
+}
@@ -26,7 +26,7 @@ var ipAllowListAnnotations = parser.Annotation{
 	Group: "ipallowlist",
 	Annotations: parser.AnnotationFields{
 		allowListAnnotation: {
-			Doc: "allow ip, e.g: `allow 1.1.1.1` , required",
+			Doc: "ip addresses or cidrs allowed to reach the ingress, the others are denied, e.g: `10.0.0.0/8,192.168.1.1,2001:db8::/32`, required",
 		},
 	},
 }
@@ -51,9 +51,11 @@ func (p *ipallowList) Parse(ing *ingressv1.Ingress) (interface{}, error) {
 		if alias == "" {
 			continue
 		}
-		if !parser.PassIsIp(alias) {
+		cidr, err := parser.ParseCIDR(alias)
+		if err != nil {
 			return nil, errors.NewInvalidAnnotationsContentError(allowListAnnotation, alias)
 		}
+		alias = cidr
 
 		if !aliases.Has(alias) {
 			aliases.Insert(alias)
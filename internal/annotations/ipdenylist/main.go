@@ -26,7 +26,7 @@ var ipDenyListAnnotations = parser.Annotation{
 	Group: "ipdenylist",
 	Annotations: parser.AnnotationFields{
 		denyListAnnotation: {
-			Doc: "deny ip, e.g: `2.2.2.2`, required",
+			Doc: "ip addresses or cidrs denied, they take precedence over allowList, e.g: `2.2.2.2,10.1.0.0/16`, required",
 		},
 	},
 }
@@ -49,9 +49,11 @@ func (p *ipdenyList) Parse(ing *ingressv1.Ingress) (interface{}, error) {
 		if alias == "" {
 			continue
 		}
-		if !parser.PassIsIp(alias) {
+		cidr, err := parser.ParseCIDR(alias)
+		if err != nil {
 			return nil, errors.NewInvalidAnnotationsContentError(denyListAnnotation, alias)
 		}
+		alias = cidr
 
 		if !aliases.Has(alias) {
 			aliases.Insert(alias)
@@ -0,0 +1,57 @@
+package ipdenylist
+
+import (
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations/testutil"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		val     string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "ipv4 and ipv6",
+			val:  "10.0.0.0/8,192.168.1.1,2001:db8::/32",
+			want: []string{"10.0.0.0/8", "192.168.1.1", "2001:db8::/32"},
+		},
+		{
+			name: "canonical and unique",
+			val:  " 10.1.2.3/8, 10.0.0.0/8 ,,2001:DB8::1",
+			want: []string{"10.0.0.0/8", "2001:db8::1"},
+		},
+		{
+			name:    "invalid address",
+			val:     "10.0.0.256",
+			wantErr: true,
+		},
+		{
+			name:    "invalid prefix",
+			val:     "2001:db8::/129",
+			wantErr: true,
+		},
+		{
+			name:    "hostname",
+			val:     "10.0.0.0/8,example.com",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewParser(nil).Parse(testutil.Ingress(map[string]string{denyListAnnotation: tt.val}))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if cidr := got.(*SourceRange).CIDR; !reflect.DeepEqual(cidr, tt.want) {
+				t.Errorf("Parse() cidr = %v, want %v", cidr, tt.want)
+			}
+		})
+	}
+}
@@ -82,14 +82,55 @@ func (a ingAnnotations) parseBool(name string) (bool, error) {
 	return false, kerr.ErrMissingAnnotations
 }
 
+func (a ingAnnotations) parseInt(name string) (int, error) {
+	val, ok := a[name]
+	if ok {
+		i, err := strconv.Atoi(val)
+		if err != nil {
+			return 0, kerr.NewInvalidContent(name, val)
+		}
+		return i, nil
+	}
+	return 0, kerr.ErrMissingAnnotations
+}
+
+// validate runs the validator of the annotation, if any, on its value
+func validate(name, key string, ing *ingressv1.Ingress, field AnnotationFields) error {
+	cfg, ok := field[name]
+	if !ok || cfg.Validator == nil {
+		return nil
+	}
+
+	val := ing.GetAnnotations()[key]
+	if err := cfg.Validator(val); err != nil {
+		return kerr.NewInvalidAnnotationsContentError(key, val)
+	}
+
+	return nil
+}
+
 func GetStringAnnotation(name string, ing *ingressv1.Ingress, field AnnotationFields) (string, error) {
 	key, err := CheckAnnotationsKey(name, ing, field)
 	if err != nil {
 		return "", err
 	}
+	if err := validate(name, key, ing, field); err != nil {
+		return "", err
+	}
 	return ingAnnotations(ing.GetAnnotations()).parseString(key)
 }
 
+func GetIntAnnotation(name string, ing *ingressv1.Ingress, field AnnotationFields) (int, error) {
+	key, err := CheckAnnotationsKey(name, ing, field)
+	if err != nil {
+		return 0, err
+	}
+	if err := validate(name, key, ing, field); err != nil {
+		return 0, err
+	}
+	return ingAnnotations(ing.GetAnnotations()).parseInt(key)
+}
+
 func GetBoolAnnotations(name string, ing *ingressv1.Ingress, field AnnotationFields) (bool, error) {
 	key, err := CheckAnnotationsKey(name, ing, field)
 	if err != nil {
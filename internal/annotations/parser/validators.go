@@ -1,15 +1,33 @@
 package parser
 
 import (
+	"crypto/x509"
+	"encoding/pem"
 	"errors"
 	"fmt"
 	ingressv1 "github.com/Lxb921006/ingress-nginx-kubebuilder/api/v1"
 	kerr "github.com/Lxb921006/ingress-nginx-kubebuilder/internal/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"net/netip"
+	"net/textproto"
 	"regexp"
+	"strconv"
+	"strings"
 )
 
 type AnnotationValidator func(string) error
 
+var (
+	headerNameRegex = regexp.MustCompile(`^[A-Za-z0-9-]+$`)
+	// the header values are rendered into double quoted strings and may hold nginx variables
+	headerValueRegex = regexp.MustCompile(`^[^"\\\x00-\x1f\x7f]*$`)
+	timeRegex        = regexp.MustCompile(`^\d+(ms|s|m|h|d)?$`)
+	sizeRegex        = regexp.MustCompile(`^\d+[kKmMgG]?$`)
+	// controllerHeaders are the request headers the templates set on every location
+	controllerHeaders = sets.New("Upgrade", "Connection", "X-Real-Ip", "X-Forwarded-For", "X-Forwarded-Host",
+		"X-Forwarded-Port", "X-Forwarded-Proto", "X-Forwarded-Scheme", "X-Scheme", "X-Original-Forwarded-For")
+)
+
 func IsRegexPatternRegex(str string) bool {
 	pattern := `^\/(?:.*\(.+\).*|.*\[[^\[\]]+\].*)`
 	matched, _ := regexp.MatchString(pattern, str)
@@ -40,9 +58,37 @@ func IsNonEmptyParenthesesRegex(str string) bool {
 }
 
 func PassIsIp(target string) bool {
-	pattern := `^(\d+).((\d+).){2}(\d+)$`
-	re := regexp.MustCompile(pattern)
-	return re.MatchString(target)
+	addr, err := netip.ParseAddr(target)
+	return err == nil && addr.Zone() == ""
+}
+
+// ParseCIDR returns the canonical form of an IPv4/IPv6 address or prefix, e.g: 10.0.0.1/8 is 10.0.0.0/8
+func ParseCIDR(str string) (string, error) {
+	if prefix, err := netip.ParsePrefix(str); err == nil {
+		return prefix.Masked().String(), nil
+	}
+
+	if !PassIsIp(str) {
+		return "", fmt.Errorf("%s is not an ip address or cidr", str)
+	}
+
+	return netip.MustParseAddr(str).String(), nil
+}
+
+// IsCABundle reports whether data holds pem encoded certificates and nothing else
+func IsCABundle(data []byte) bool {
+	found := false
+	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type != "CERTIFICATE" {
+			return false
+		}
+		if _, err := x509.ParseCertificate(block.Bytes); err != nil {
+			return false
+		}
+		found = true
+	}
+
+	return found
 }
 
 func IsValidHost(host string) bool {
@@ -96,3 +142,103 @@ func CheckAnnotationsKey(name string, ing *ingressv1.Ingress, field AnnotationFi
 
 	return annotationFullName, nil
 }
+
+// ValidateTime accepts an nginx time, e.g: 500ms, 5s, 1m
+func ValidateTime(val string) error {
+	if !timeRegex.MatchString(val) {
+		return fmt.Errorf("%s is not a valid time", val)
+	}
+	return nil
+}
+
+// ValidateSize accepts an nginx size, e.g: 4k, 8m, 1g, 0 disables the check of client_max_body_size
+func ValidateSize(val string) error {
+	if !sizeRegex.MatchString(val) {
+		return fmt.Errorf("%s is not a valid size", val)
+	}
+	return nil
+}
+
+// ValidateIntRange accepts an integer between min and max
+func ValidateIntRange(min, max int) AnnotationValidator {
+	return func(val string) error {
+		i, err := strconv.Atoi(val)
+		if err != nil || i < min || i > max {
+			return fmt.Errorf("%s is not an integer between %d and %d", val, min, max)
+		}
+		return nil
+	}
+}
+
+// ValidateRegex accepts the values matching the pattern
+func ValidateRegex(pattern string) AnnotationValidator {
+	re := regexp.MustCompile(pattern)
+	return func(val string) error {
+		if !re.MatchString(val) {
+			return fmt.Errorf("%s does not match %s", val, pattern)
+		}
+		return nil
+	}
+}
+
+// IsHeaderName reports whether name is a valid http header name
+func IsHeaderName(name string) bool {
+	return headerNameRegex.MatchString(name)
+}
+
+// IsHeaderValue reports whether val can be rendered as the value of a header
+func IsHeaderValue(val string) bool {
+	return headerValueRegex.MatchString(val)
+}
+
+// IsControllerHeader reports whether the request header name is set by the controller and can not be changed
+func IsControllerHeader(name string) bool {
+	return controllerHeaders.Has(textproto.CanonicalMIMEHeaderKey(name))
+}
+
+// ParseHeaders validates the headers and returns them by their canonical name
+func ParseHeaders(headers map[string]string) (map[string]string, error) {
+	parsed := make(map[string]string, len(headers))
+	for name, val := range headers {
+		if !IsHeaderName(name) {
+			return nil, fmt.Errorf("%s is not a valid header name", name)
+		}
+		if !IsHeaderValue(val) {
+			return nil, fmt.Errorf("%s is not a valid value of header %s", val, name)
+		}
+		parsed[textproto.CanonicalMIMEHeaderKey(name)] = val
+	}
+
+	return parsed, nil
+}
+
+// ParseHeaderLines parses one `Name: value` header per line, e.g: `X-Env: prod\nX-Host: $host`
+func ParseHeaderLines(val string) (map[string]string, error) {
+	headers := make(map[string]string)
+	for _, line := range strings.Split(val, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("%s is not a `Name: value` header", line)
+		}
+		headers[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+
+	return ParseHeaders(headers)
+}
+
+// ParseHeaderNames parses a comma separated list of header names, e.g: `X-Debug, Server`
+func ParseHeaderNames(val string) ([]string, error) {
+	names := sets.New[string]()
+	for _, name := range strings.Split(val, ",") {
+		name = strings.TrimSpace(name)
+		if !IsHeaderName(name) {
+			return nil, fmt.Errorf("%s is not a valid header name", name)
+		}
+		names.Insert(textproto.CanonicalMIMEHeaderKey(name))
+	}
+
+	return sets.List(names), nil
+}
@@ -0,0 +1,131 @@
+package proxysettings
+
+import (
+	ingressv1 "github.com/Lxb921006/ingress-nginx-kubebuilder/api/v1"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations/parser"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations/resolver"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/errors"
+	"k8s.io/klog/v2"
+)
+
+const (
+	proxyConnectTimeoutAnnotation    = "proxy-connect-timeout"
+	proxyReadTimeoutAnnotation       = "proxy-read-timeout"
+	proxySendTimeoutAnnotation       = "proxy-send-timeout"
+	proxyBodySizeAnnotation          = "proxy-body-size"
+	proxyBufferingAnnotation         = "proxy-buffering"
+	proxyBufferSizeAnnotation        = "proxy-buffer-size"
+	proxyNextUpstreamTriesAnnotation = "proxy-next-upstream-tries"
+)
+
+const (
+	defaultProxyConnectTimeout    = "5s"
+	defaultProxyReadTimeout       = "60s"
+	defaultProxySendTimeout       = "60s"
+	defaultProxyBufferSize        = "4k"
+	defaultProxyNextUpstreamTries = 3
+)
+
+var proxySettingsAnnotations = parser.Annotation{
+	Group: "proxySettings",
+	Annotations: parser.AnnotationFields{
+		proxyConnectTimeoutAnnotation: {
+			Doc:       "timeout for establishing a connection with the backend, e.g: `5s`, optional, defaults to 5s",
+			Validator: parser.ValidateTime,
+		},
+		proxyReadTimeoutAnnotation: {
+			Doc:       "timeout between two successive reads of the response of the backend, e.g: `60s`, optional, defaults to 60s",
+			Validator: parser.ValidateTime,
+		},
+		proxySendTimeoutAnnotation: {
+			Doc:       "timeout between two successive writes of the request to the backend, e.g: `60s`, optional, defaults to 60s",
+			Validator: parser.ValidateTime,
+		},
+		proxyBodySizeAnnotation: {
+			Doc:       "maximum size of the request body, 0 disables the check, e.g: `8m`, optional, defaults to proxy-body-size of the configmap",
+			Validator: parser.ValidateSize,
+		},
+		proxyBufferingAnnotation: {
+			Doc: "buffer the responses of the backend, e.g: `true or false`, optional, defaults to false",
+		},
+		proxyBufferSizeAnnotation: {
+			Doc:       "size of the buffers reading the responses of the backend, e.g: `4k`, optional, defaults to 4k",
+			Validator: parser.ValidateSize,
+		},
+		proxyNextUpstreamTriesAnnotation: {
+			Doc:       "number of servers tried when a request fails, 0 means no limit, e.g: `3`, optional, defaults to 3",
+			Validator: parser.ValidateIntRange(0, 100),
+		},
+	},
+}
+
+// Config holds the proxy_* directives rendered into every location of the ingress
+type Config struct {
+	ConnectTimeout    string `json:"connect-timeout"`
+	ReadTimeout       string `json:"read-timeout"`
+	SendTimeout       string `json:"send-timeout"`
+	BodySize          string `json:"body-size"`
+	Buffering         bool   `json:"buffering"`
+	BufferSize        string `json:"buffer-size"`
+	NextUpstreamTries int    `json:"next-upstream-tries"`
+}
+
+type proxySettings struct {
+	r resolver.Resolver
+}
+
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return &proxySettings{}
+}
+
+// Parse Every setting falls back to the value nginx used before it could be set, an invalid value rejects the ingress.
+// An empty body size leaves client_max_body_size to the http block.
+func (p *proxySettings) Parse(ing *ingressv1.Ingress) (interface{}, error) {
+	var err error
+	config := &Config{
+		ConnectTimeout:    defaultProxyConnectTimeout,
+		ReadTimeout:       defaultProxyReadTimeout,
+		SendTimeout:       defaultProxySendTimeout,
+		BufferSize:        defaultProxyBufferSize,
+		NextUpstreamTries: defaultProxyNextUpstreamTries,
+	}
+
+	for name, field := range map[string]*string{
+		proxyConnectTimeoutAnnotation: &config.ConnectTimeout,
+		proxyReadTimeoutAnnotation:    &config.ReadTimeout,
+		proxySendTimeoutAnnotation:    &config.SendTimeout,
+		proxyBodySizeAnnotation:       &config.BodySize,
+		proxyBufferSizeAnnotation:     &config.BufferSize,
+	} {
+		val, err := parser.GetStringAnnotation(name, ing, proxySettingsAnnotations.Annotations)
+		if err != nil {
+			if errors.IsInvalidAnnotationsContentError(err) {
+				return nil, err
+			}
+			continue
+		}
+		*field = val
+	}
+
+	config.Buffering, err = parser.GetBoolAnnotations(proxyBufferingAnnotation, ing, proxySettingsAnnotations.Annotations)
+	if err != nil {
+		if errors.IsValidationError(err) {
+			klog.V(5).Infof("%s is not set, defaulting to false", proxyBufferingAnnotation)
+		}
+	}
+
+	tries, err := parser.GetIntAnnotation(proxyNextUpstreamTriesAnnotation, ing, proxySettingsAnnotations.Annotations)
+	if err != nil {
+		if errors.IsInvalidAnnotationsContentError(err) {
+			return nil, err
+		}
+	} else {
+		config.NextUpstreamTries = tries
+	}
+
+	return config, nil
+}
+
+func (p *proxySettings) Validate(anns map[string]string) error {
+	return parser.CheckAnnotations(anns, proxySettingsAnnotations.Annotations)
+}
@@ -0,0 +1,108 @@
+package proxysettings
+
+import (
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations/testutil"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	defaults := Config{
+		ConnectTimeout:    defaultProxyConnectTimeout,
+		ReadTimeout:       defaultProxyReadTimeout,
+		SendTimeout:       defaultProxySendTimeout,
+		BufferSize:        defaultProxyBufferSize,
+		NextUpstreamTries: defaultProxyNextUpstreamTries,
+	}
+
+	tests := []struct {
+		name    string
+		anns    map[string]string
+		want    func(c *Config)
+		wantErr bool
+	}{
+		{
+			name: "defaults",
+			anns: map[string]string{},
+			want: func(c *Config) {},
+		},
+		{
+			name: "settings",
+			anns: map[string]string{
+				proxyConnectTimeoutAnnotation:    "500ms",
+				proxyReadTimeoutAnnotation:       "5m",
+				proxySendTimeoutAnnotation:       "120",
+				proxyBodySizeAnnotation:          "0",
+				proxyBufferingAnnotation:         "true",
+				proxyBufferSizeAnnotation:        "16K",
+				proxyNextUpstreamTriesAnnotation: "0",
+			},
+			want: func(c *Config) {
+				c.ConnectTimeout = "500ms"
+				c.ReadTimeout = "5m"
+				c.SendTimeout = "120"
+				c.BodySize = "0"
+				c.Buffering = true
+				c.BufferSize = "16K"
+				c.NextUpstreamTries = 0
+			},
+		},
+		{
+			name: "empty value keeps the default",
+			anns: map[string]string{proxySendTimeoutAnnotation: ""},
+			want: func(c *Config) {},
+		},
+		{
+			name: "invalid buffering defaults to false",
+			anns: map[string]string{proxyBufferingAnnotation: "on"},
+			want: func(c *Config) {},
+		},
+		{
+			name:    "invalid time",
+			anns:    map[string]string{proxyReadTimeoutAnnotation: "60 s"},
+			wantErr: true,
+		},
+		{
+			name:    "time with a directive",
+			anns:    map[string]string{proxyConnectTimeoutAnnotation: "5s; return 200"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid size",
+			anns:    map[string]string{proxyBodySizeAnnotation: "8mb"},
+			wantErr: true,
+		},
+		{
+			name:    "negative buffer size",
+			anns:    map[string]string{proxyBufferSizeAnnotation: "-4k"},
+			wantErr: true,
+		},
+		{
+			name:    "tries out of range",
+			anns:    map[string]string{proxyNextUpstreamTriesAnnotation: "101"},
+			wantErr: true,
+		},
+		{
+			name:    "tries not a number",
+			anns:    map[string]string{proxyNextUpstreamTriesAnnotation: "three"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewParser(nil).Parse(testutil.Ingress(tt.anns))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			want := defaults
+			tt.want(&want)
+			if !reflect.DeepEqual(got, &want) {
+				t.Errorf("Parse() = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
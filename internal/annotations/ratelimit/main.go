@@ -0,0 +1,135 @@
+package ratelimit
+
+import (
+	"fmt"
+	ingressv1 "github.com/Lxb921006/ingress-nginx-kubebuilder/api/v1"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations/parser"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations/resolver"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/errors"
+	"hash/fnv"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"strings"
+)
+
+const (
+	limitRPSAnnotation         = "limit-rps"
+	limitRPMAnnotation         = "limit-rpm"
+	limitBurstAnnotation       = "limit-burst"
+	limitConnectionsAnnotation = "limit-connections"
+	limitAllowlistAnnotation   = "limit-allowlist"
+	// defaultBurstMultiplier sets the burst of a zone to a multiple of its rate when limit-burst is not set
+	defaultBurstMultiplier = 5
+)
+
+var rateLimitAnnotations = parser.Annotation{
+	Group: "rateLimit",
+	Annotations: parser.AnnotationFields{
+		limitRPSAnnotation: {
+			Doc:       "requests per second accepted from a client ip, e.g: `10`, optional",
+			Validator: parser.ValidateIntRange(1, 1000000),
+		},
+		limitRPMAnnotation: {
+			Doc:       "requests per minute accepted from a client ip, e.g: `300`, optional",
+			Validator: parser.ValidateIntRange(1, 1000000),
+		},
+		limitBurstAnnotation: {
+			Doc:       "requests of a client ip above the rate served without delay before rejecting them, e.g: `20`, optional, defaults to 5 times the rate",
+			Validator: parser.ValidateIntRange(0, 1000000),
+		},
+		limitConnectionsAnnotation: {
+			Doc:       "concurrent connections accepted from a client ip, e.g: `10`, optional",
+			Validator: parser.ValidateIntRange(1, 1000000),
+		},
+		limitAllowlistAnnotation: {
+			Doc: "client ips exempt from the limits, e.g: `10.0.0.0/8,192.168.1.1`, optional",
+		},
+	},
+}
+
+// Config limits the requests and connections of every client ip to the locations of the ingress. The zones
+// are declared at http level by the configuration file of the ingress, named after it so they never collide.
+type Config struct {
+	RPS         int      `json:"rps"`
+	RPM         int      `json:"rpm"`
+	RPSBurst    int      `json:"rps-burst"`
+	RPMBurst    int      `json:"rpm-burst"`
+	Connections int      `json:"connections"`
+	Allowlist   []string `json:"allowlist"`
+	// Name prefixes the zones and variables of the ingress
+	Name string `json:"name"`
+	// Key is the variable the zones are keyed by, empty for the clients of the allowlist
+	Key string `json:"key"`
+}
+
+type rateLimit struct {
+	r resolver.Resolver
+}
+
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return &rateLimit{}
+}
+
+func (l *rateLimit) Parse(ing *ingressv1.Ingress) (interface{}, error) {
+	config := &Config{}
+
+	for name, field := range map[string]*int{
+		limitRPSAnnotation:         &config.RPS,
+		limitRPMAnnotation:         &config.RPM,
+		limitConnectionsAnnotation: &config.Connections,
+	} {
+		val, err := parser.GetIntAnnotation(name, ing, rateLimitAnnotations.Annotations)
+		if err != nil {
+			if errors.IsInvalidAnnotationsContentError(err) {
+				return nil, err
+			}
+			continue
+		}
+		*field = val
+	}
+
+	config.RPSBurst = config.RPS * defaultBurstMultiplier
+	config.RPMBurst = config.RPM * defaultBurstMultiplier
+	burst, err := parser.GetIntAnnotation(limitBurstAnnotation, ing, rateLimitAnnotations.Annotations)
+	if err != nil {
+		if errors.IsInvalidAnnotationsContentError(err) {
+			return nil, err
+		}
+	} else {
+		config.RPSBurst, config.RPMBurst = burst, burst
+	}
+
+	allowlist, _ := parser.GetStringAnnotation(limitAllowlistAnnotation, ing, rateLimitAnnotations.Annotations)
+	cidrs := sets.NewString()
+	for _, alias := range strings.Split(allowlist, ",") {
+		alias = strings.TrimSpace(alias)
+		if alias == "" {
+			continue
+		}
+		cidr, err := parser.ParseCIDR(alias)
+		if err != nil {
+			return nil, errors.NewInvalidAnnotationsContentError(parser.GetAnnotationWithPrefix(limitAllowlistAnnotation), alias)
+		}
+		cidrs.Insert(cidr)
+	}
+	config.Allowlist = cidrs.List()
+
+	h := fnv.New32a()
+	h.Write([]byte(ing.Namespace + "/" + ing.Name))
+	config.Name = fmt.Sprintf("limit_%08x", h.Sum32())
+
+	config.Key = "binary_remote_addr"
+	if len(config.Allowlist) > 0 {
+		config.Key = config.Name + "_key"
+	}
+
+	return config, nil
+}
+
+func (l *rateLimit) Validate(anns map[string]string) error {
+	return parser.CheckAnnotations(anns, rateLimitAnnotations.Annotations)
+}
+
+// Enabled reports whether the ingress limits its clients at all
+func (c Config) Enabled() bool {
+	return c.RPS > 0 || c.RPM > 0 || c.Connections > 0
+}
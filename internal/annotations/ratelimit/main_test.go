@@ -0,0 +1,111 @@
+package ratelimit
+
+import (
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations/testutil"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	// name is derived from the namespace and name of the ingress
+	const name = "limit_d878a988"
+
+	tests := []struct {
+		name    string
+		anns    map[string]string
+		want    *Config
+		enabled bool
+		wantErr bool
+	}{
+		{
+			name: "no limits",
+			anns: map[string]string{},
+			want: &Config{Allowlist: []string{}, Name: name, Key: "binary_remote_addr"},
+		},
+		{
+			name:    "default burst",
+			anns:    map[string]string{limitRPSAnnotation: "10", limitRPMAnnotation: "300"},
+			want:    &Config{RPS: 10, RPM: 300, RPSBurst: 50, RPMBurst: 1500, Allowlist: []string{}, Name: name, Key: "binary_remote_addr"},
+			enabled: true,
+		},
+		{
+			name:    "burst",
+			anns:    map[string]string{limitRPSAnnotation: "10", limitBurstAnnotation: "0"},
+			want:    &Config{RPS: 10, Allowlist: []string{}, Name: name, Key: "binary_remote_addr"},
+			enabled: true,
+		},
+		{
+			name:    "connections",
+			anns:    map[string]string{limitConnectionsAnnotation: "3"},
+			want:    &Config{Connections: 3, Allowlist: []string{}, Name: name, Key: "binary_remote_addr"},
+			enabled: true,
+		},
+		{
+			name:    "allowlist is keyed by its own variable",
+			anns:    map[string]string{limitRPSAnnotation: "1", limitAllowlistAnnotation: "192.168.1.1, 10.0.0.0/8,,10.0.0.0/8"},
+			want:    &Config{RPS: 1, RPSBurst: 5, Allowlist: []string{"10.0.0.0/8", "192.168.1.1"}, Name: name, Key: name + "_key"},
+			enabled: true,
+		},
+		{
+			name:    "rate out of range",
+			anns:    map[string]string{limitRPSAnnotation: "0"},
+			wantErr: true,
+		},
+		{
+			name:    "rate not a number",
+			anns:    map[string]string{limitRPMAnnotation: "10r/s"},
+			wantErr: true,
+		},
+		{
+			name:    "negative burst",
+			anns:    map[string]string{limitRPSAnnotation: "10", limitBurstAnnotation: "-1"},
+			wantErr: true,
+		},
+		{
+			name:    "connections out of range",
+			anns:    map[string]string{limitConnectionsAnnotation: "1000001"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid allowlist",
+			anns:    map[string]string{limitRPSAnnotation: "10", limitAllowlistAnnotation: "10.0.0.0/8,localhost"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewParser(nil).Parse(testutil.Ingress(tt.anns))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			c := got.(*Config)
+			if !reflect.DeepEqual(c, tt.want) {
+				t.Errorf("Parse() = %+v, want %+v", c, tt.want)
+			}
+			if c.Enabled() != tt.enabled {
+				t.Errorf("Enabled() = %v, want %v", c.Enabled(), tt.enabled)
+			}
+		})
+	}
+}
+
+func TestParseName(t *testing.T) {
+	name := func(namespace string) string {
+		ing := testutil.Ingress(nil)
+		ing.Namespace = namespace
+		got, err := NewParser(nil).Parse(ing)
+		if err != nil {
+			t.Fatalf("Parse() error = %v", err)
+		}
+		return got.(*Config).Name
+	}
+
+	if name("default") == name("other") {
+		t.Errorf("Parse() name of ingresses of different namespaces collides")
+	}
+}
@@ -1,33 +1,66 @@
 package redirect
 
 import (
+	"fmt"
 	ingressv1 "github.com/Lxb921006/ingress-nginx-kubebuilder/api/v1"
 	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations/parser"
 	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations/resolver"
 	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/errors"
 	"k8s.io/klog/v2"
+	"regexp"
 )
 
 const (
-	serverRedirectHostAnnotation = "redirect-host"
-	serverRedirectPathAnnotation = "redirect-path"
+	serverRedirectHostAnnotation        = "redirect-host"
+	serverRedirectPathAnnotation        = "redirect-path"
+	serverRedirectCodeAnnotation        = "redirect-code"
+	serverRedirectSchemeAnnotation      = "redirect-scheme"
+	serverRedirectPreserveURIAnnotation = "redirect-preserve-uri"
+	serverRedirectEnableRegexAnnotation = "redirect-enable-regex"
+	forceSSLRedirectAnnotation          = "force-ssl-redirect"
+	defaultRedirectCode                 = 301
+	defaultRedirectScheme               = "http"
 )
 
 var redirectAnnotation = parser.Annotation{
 	Group: "redirect",
 	Annotations: parser.AnnotationFields{
 		serverRedirectHostAnnotation: {
-			Doc: "return 301 e.g: `http://*`, required",
+			Doc:       "host, optionally followed by a port and a path, the matching requests are redirected to, e.g: `b.com` or `b.com:8443/new`, required",
+			Validator: parser.ValidateRegex(`^[A-Za-z0-9.-]+(:\d+)?(/[^\s"'{};\\]*)?$`),
 		},
 		serverRedirectPathAnnotation: {
-			Doc: "match path redirect, e.g: `/aaa`, optional",
+			Doc:       "match path redirect, e.g: `/aaa` or regex: `/aaa/.*` with redirect-enable-regex, optional",
+			Validator: parser.ValidateRegex(`^/[^\s"'{};\\]*$`),
+		},
+		serverRedirectCodeAnnotation: {
+			Doc:       "status code of the redirect, 301 and 308 are permanent, 302 and 307 temporary, e.g: `302`, optional, defaults to 301",
+			Validator: parser.ValidateRegex(`^(301|302|307|308)$`),
+		},
+		serverRedirectSchemeAnnotation: {
+			Doc:       "scheme of the redirect, e.g: `http or https`, optional, defaults to http",
+			Validator: parser.ValidateRegex(`^https?$`),
+		},
+		serverRedirectPreserveURIAnnotation: {
+			Doc: "append the uri and the query string of the request to the redirect, e.g: `true or false`, optional",
+		},
+		serverRedirectEnableRegexAnnotation: {
+			Doc: "redirect-path is a regular expression, e.g: `true or false`, optional",
+		},
+		forceSSLRedirectAnnotation: {
+			Doc: "redirect the plain http requests of the host to https, requires the tls of the ingress, e.g: `true or false`, optional",
 		},
 	},
 }
 
 type Config struct {
-	Host string `json:"host"`
-	Path string `json:"path"`
+	Host        string `json:"host"`
+	Path        string `json:"path"`
+	Code        int    `json:"code"`
+	Scheme      string `json:"scheme"`
+	PreserveURI bool   `json:"preserve-uri"`
+	EnableRegex bool   `json:"enable-regex"`
+	ForceSSL    bool   `json:"force-ssl-redirect"`
 }
 
 type redirect struct {
@@ -40,18 +73,42 @@ func NewParser(r resolver.Resolver) parser.IngressAnnotation {
 
 func (r *redirect) Parse(ing *ingressv1.Ingress) (interface{}, error) {
 	var err error
-	config := &Config{}
-	config.Host, err = parser.GetStringAnnotation(serverRedirectHostAnnotation, ing, redirectAnnotation.Annotations)
+	config := &Config{
+		Code:   defaultRedirectCode,
+		Scheme: defaultRedirectScheme,
+	}
+
+	for name, field := range map[string]*string{
+		serverRedirectHostAnnotation:   &config.Host,
+		serverRedirectPathAnnotation:   &config.Path,
+		serverRedirectSchemeAnnotation: &config.Scheme,
+	} {
+		val, err := parser.GetStringAnnotation(name, ing, redirectAnnotation.Annotations)
+		if err != nil {
+			if errors.IsInvalidAnnotationsContentError(err) {
+				return nil, err
+			}
+			continue
+		}
+		*field = val
+	}
+
+	code, err := parser.GetIntAnnotation(serverRedirectCodeAnnotation, ing, redirectAnnotation.Annotations)
 	if err != nil {
-		if errors.IsValidationError(err) {
-			klog.Warningf("%s is invalid, defaulting to empty", serverRedirectHostAnnotation)
+		if errors.IsInvalidAnnotationsContentError(err) {
+			return nil, err
 		}
+	} else {
+		config.Code = code
 	}
 
-	config.Path, err = parser.GetStringAnnotation(serverRedirectPathAnnotation, ing, redirectAnnotation.Annotations)
+	config.PreserveURI, _ = parser.GetBoolAnnotations(serverRedirectPreserveURIAnnotation, ing, redirectAnnotation.Annotations)
+	config.EnableRegex, _ = parser.GetBoolAnnotations(serverRedirectEnableRegexAnnotation, ing, redirectAnnotation.Annotations)
+
+	config.ForceSSL, err = parser.GetBoolAnnotations(forceSSLRedirectAnnotation, ing, redirectAnnotation.Annotations)
 	if err != nil {
 		if errors.IsValidationError(err) {
-			klog.Warningf("%s is invalid, defaulting to empty", serverRedirectPathAnnotation)
+			klog.Warningf("%s is invalid, defaulting to false", forceSSLRedirectAnnotation)
 		}
 	}
 
@@ -59,6 +116,18 @@ func (r *redirect) Parse(ing *ingressv1.Ingress) (interface{}, error) {
 		return nil, errors.NewInvalidAnnotationsContentError(serverRedirectHostAnnotation, config.Host)
 	}
 
+	if config.EnableRegex {
+		if _, err := regexp.Compile(config.Path); err != nil || config.Path == "" || config.Path[0] != '/' {
+			return nil, errors.NewInvalidAnnotationsContentError(serverRedirectPathAnnotation, config.Path)
+		}
+		config.Path = `~ "^` + config.Path + `"`
+	}
+
+	if config.ForceSSL && len(ing.Spec.TLS) == 0 {
+		msg := fmt.Sprintf("annotation %s requires the tls of the ingress, ingress name %s", forceSSLRedirectAnnotation, ing.Name)
+		return nil, errors.NewNotSatisfiableError(msg)
+	}
+
 	return config, nil
 }
 
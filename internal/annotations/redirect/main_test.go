@@ -0,0 +1,118 @@
+package redirect
+
+import (
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations/testutil"
+	netv1 "k8s.io/api/networking/v1"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		anns    map[string]string
+		tls     bool
+		want    *Config
+		wantErr bool
+	}{
+		{
+			name: "path",
+			anns: map[string]string{serverRedirectHostAnnotation: "b.com", serverRedirectPathAnnotation: "/aaa"},
+			want: &Config{Host: "b.com", Path: "/aaa", Code: defaultRedirectCode, Scheme: defaultRedirectScheme},
+		},
+		{
+			name: "regex path is quoted",
+			anns: map[string]string{
+				serverRedirectHostAnnotation:        "b.com",
+				serverRedirectPathAnnotation:        "/aaa/.*",
+				serverRedirectEnableRegexAnnotation: "true",
+			},
+			want: &Config{Host: "b.com", Path: `~ "^/aaa/.*"`, Code: defaultRedirectCode, Scheme: defaultRedirectScheme, EnableRegex: true},
+		},
+		{
+			name: "code, scheme and uri",
+			anns: map[string]string{
+				serverRedirectHostAnnotation:        "b.com:8443/new",
+				serverRedirectCodeAnnotation:        "308",
+				serverRedirectSchemeAnnotation:      "https",
+				serverRedirectPreserveURIAnnotation: "true",
+			},
+			want: &Config{Host: "b.com:8443/new", Code: 308, Scheme: "https", PreserveURI: true},
+		},
+		{
+			name: "force ssl redirect",
+			anns: map[string]string{forceSSLRedirectAnnotation: "true"},
+			tls:  true,
+			want: &Config{Code: defaultRedirectCode, Scheme: defaultRedirectScheme, ForceSSL: true},
+		},
+		{
+			name:    "force ssl redirect without tls",
+			anns:    map[string]string{forceSSLRedirectAnnotation: "true"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown code",
+			anns:    map[string]string{serverRedirectHostAnnotation: "b.com", serverRedirectCodeAnnotation: "303"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown scheme",
+			anns:    map[string]string{serverRedirectHostAnnotation: "b.com", serverRedirectSchemeAnnotation: "ftp"},
+			wantErr: true,
+		},
+		{
+			name:    "host with a directive",
+			anns:    map[string]string{serverRedirectHostAnnotation: "b.com; return 200"},
+			wantErr: true,
+		},
+		{
+			name:    "block injection",
+			anns:    map[string]string{serverRedirectHostAnnotation: "b.com", serverRedirectPathAnnotation: "/x { return 200; } location /y"},
+			wantErr: true,
+		},
+		{
+			name:    "quote",
+			anns:    map[string]string{serverRedirectHostAnnotation: "b.com", serverRedirectPathAnnotation: `/x"`},
+			wantErr: true,
+		},
+		{
+			name:    "backslash",
+			anns:    map[string]string{serverRedirectHostAnnotation: "b.com", serverRedirectPathAnnotation: `/x\`},
+			wantErr: true,
+		},
+		{
+			name:    "relative path",
+			anns:    map[string]string{serverRedirectHostAnnotation: "b.com", serverRedirectPathAnnotation: "aaa"},
+			wantErr: true,
+		},
+		{
+			name: "invalid regex",
+			anns: map[string]string{
+				serverRedirectHostAnnotation:        "b.com",
+				serverRedirectPathAnnotation:        "/aaa/(",
+				serverRedirectEnableRegexAnnotation: "true",
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ing := testutil.Ingress(tt.anns)
+			if tt.tls {
+				ing.Spec.TLS = []netv1.IngressTLS{{Hosts: []string{"a.com"}, SecretName: "a-tls"}}
+			}
+
+			got, err := NewParser(nil).Parse(ing)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
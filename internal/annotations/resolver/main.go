@@ -2,6 +2,7 @@ package resolver
 
 import (
 	corev1 "k8s.io/api/core/v1"
+	netv1 "k8s.io/api/networking/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -12,4 +13,5 @@ type Resolver interface {
 	GetHostName() []string
 	GetSvcPort(interface{}) int32
 	GetTlsData(client.ObjectKey) (map[string][]byte, error)
+	GetEndpoints(*corev1.Service, netv1.ServiceBackendPort) ([]string, error)
 }
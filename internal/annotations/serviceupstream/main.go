@@ -0,0 +1,54 @@
+package serviceupstream
+
+import (
+	ingressv1 "github.com/Lxb921006/ingress-nginx-kubebuilder/api/v1"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations/parser"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations/resolver"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/errors"
+	"k8s.io/klog/v2"
+)
+
+const (
+	serviceUpstreamAnnotation = "service-upstream"
+)
+
+var serviceUpstreamAnnotations = parser.Annotation{
+	Group: "serviceUpstream",
+	Annotations: parser.AnnotationFields{
+		serviceUpstreamAnnotation: {
+			Doc: "proxy to the ClusterIP of the service instead of the ready pod endpoints, e.g: `true or false`, optional",
+		},
+	},
+}
+
+type Config struct {
+	ServiceUpstream bool `json:"service-upstream"`
+}
+
+type serviceUpstream struct {
+	r resolver.Resolver
+}
+
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return &serviceUpstream{}
+}
+
+// Parse By default every ready pod behind a service becomes a server of the upstream,
+// service-upstream falls back to a single server pointing at <svc>.<namespace>.svc
+func (s *serviceUpstream) Parse(ing *ingressv1.Ingress) (interface{}, error) {
+	var err error
+	config := &Config{}
+
+	config.ServiceUpstream, err = parser.GetBoolAnnotations(serviceUpstreamAnnotation, ing, serviceUpstreamAnnotations.Annotations)
+	if err != nil {
+		if errors.IsValidationError(err) {
+			klog.Warningf("%s is invalid, defaulting to false", serviceUpstreamAnnotation)
+		}
+	}
+
+	return config, nil
+}
+
+func (s *serviceUpstream) Validate(anns map[string]string) error {
+	return parser.CheckAnnotations(anns, serviceUpstreamAnnotations.Annotations)
+}
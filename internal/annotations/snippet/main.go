@@ -0,0 +1,75 @@
+package snippet
+
+import (
+	"fmt"
+	ingressv1 "github.com/Lxb921006/ingress-nginx-kubebuilder/api/v1"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations/parser"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations/resolver"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/config"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/errors"
+)
+
+const (
+	serverSnippetAnnotation        = "server-snippet"
+	configurationSnippetAnnotation = "configuration-snippet"
+)
+
+var snippetAnnotations = parser.Annotation{
+	Group: "snippet",
+	Annotations: parser.AnnotationFields{
+		serverSnippetAnnotation: {
+			Doc:       "nginx directives injected into the server blocks of the hosts of the ingress, requires the allow-snippet-annotations flag, e.g: `more_set_headers \"X-Server: edge\";`, optional",
+			Validator: ingressv1.CheckSnippet,
+		},
+		configurationSnippetAnnotation: {
+			Doc:       "nginx directives injected into the locations of the paths of the ingress, requires the allow-snippet-annotations flag, e.g: `expires 1h;`, optional",
+			Validator: ingressv1.CheckSnippet,
+		},
+	},
+}
+
+// Config holds the raw nginx directives of the ingress, lua, load_module, include, root and alias are denied
+type Config struct {
+	ServerSnippet        string `json:"server-snippet"`
+	ConfigurationSnippet string `json:"configuration-snippet"`
+}
+
+type snippet struct {
+	r resolver.Resolver
+}
+
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return &snippet{}
+}
+
+func (s *snippet) Parse(ing *ingressv1.Ingress) (interface{}, error) {
+	config := &Config{}
+
+	for name, field := range map[string]*string{
+		serverSnippetAnnotation:        &config.ServerSnippet,
+		configurationSnippetAnnotation: &config.ConfigurationSnippet,
+	} {
+		val, err := parser.GetStringAnnotation(name, ing, snippetAnnotations.Annotations)
+		if err != nil {
+			if errors.IsInvalidAnnotationsContentError(err) {
+				return nil, err
+			}
+			continue
+		}
+		if !allowed() {
+			msg := fmt.Sprintf("annotation %s requires the allow-snippet-annotations flag of the controller, ingress name %s", name, ing.Name)
+			return nil, errors.NewNotSatisfiableError(msg)
+		}
+		*field = val
+	}
+
+	return config, nil
+}
+
+func (s *snippet) Validate(anns map[string]string) error {
+	return parser.CheckAnnotations(anns, snippetAnnotations.Annotations)
+}
+
+func allowed() bool {
+	return config.GetGlobal().AllowSnippetAnnotations
+}
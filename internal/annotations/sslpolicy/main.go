@@ -0,0 +1,192 @@
+package sslpolicy
+
+import (
+	"fmt"
+	ingressv1 "github.com/Lxb921006/ingress-nginx-kubebuilder/api/v1"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations/parser"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations/resolver"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/config"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/errors"
+	"strings"
+)
+
+const (
+	sslProtocolsAnnotation          = "ssl-protocols"
+	sslCiphersAnnotation            = "ssl-ciphers"
+	sslSessionTimeoutAnnotation     = "ssl-session-timeout"
+	sslSessionTicketsAnnotation     = "ssl-session-tickets"
+	hstsAnnotation                  = "hsts"
+	hstsMaxAgeAnnotation            = "hsts-max-age"
+	hstsIncludeSubdomainsAnnotation = "hsts-include-subdomains"
+	hstsPreloadAnnotation           = "hsts-preload"
+	disableHTTPAnnotation           = "disable-http"
+)
+
+var sslPolicyAnnotations = parser.Annotation{
+	Group: "sslPolicy",
+	Annotations: parser.AnnotationFields{
+		sslProtocolsAnnotation: {
+			Doc:       "tls protocols accepted by the host, e.g: `TLSv1.2 TLSv1.3`, optional, defaults to the ssl-protocols of the configmap",
+			Validator: parser.ValidateRegex(`^TLSv1(\.[123])?(\s+TLSv1(\.[123])?)*$`),
+		},
+		sslCiphersAnnotation: {
+			Doc:       "ciphers accepted by the host in the openssl format, e.g: `ECDHE-ECDSA-AES128-GCM-SHA256:ECDHE-RSA-AES128-GCM-SHA256`, optional, defaults to the ssl-ciphers of the configmap",
+			Validator: parser.ValidateRegex(`^[\w!+:@.-]+$`),
+		},
+		sslSessionTimeoutAnnotation: {
+			Doc:       "time a client may reuse its tls session, e.g: `1h`, optional, defaults to the ssl-session-timeout of the configmap",
+			Validator: parser.ValidateTime,
+		},
+		sslSessionTicketsAnnotation: {
+			Doc: "resume the tls sessions with session tickets, e.g: `true or false`, optional, defaults to the ssl-session-tickets of the configmap",
+		},
+		hstsAnnotation: {
+			Doc: "send the Strict-Transport-Security header, e.g: `true or false`, optional, defaults to the hsts of the configmap",
+		},
+		hstsMaxAgeAnnotation: {
+			Doc:       "seconds the browser only uses https for the host, e.g: `31536000`, optional, defaults to the hsts-max-age of the configmap",
+			Validator: parser.ValidateIntRange(0, 315360000),
+		},
+		hstsIncludeSubdomainsAnnotation: {
+			Doc: "the Strict-Transport-Security header applies to the subdomains of the host, e.g: `true or false`, optional",
+		},
+		hstsPreloadAnnotation: {
+			Doc: "allow the host in the hsts preload list of the browsers, e.g: `true or false`, optional",
+		},
+		disableHTTPAnnotation: {
+			Doc: "the host is only served on port 443, requires the tls of the ingress, e.g: `true or false`, optional",
+		},
+	},
+}
+
+// Config overrides the tls settings of the configmap for the hosts of the ingress, the unset
+// fields are nil or empty and fall back to the configmap in Merge
+type Config struct {
+	Protocols             string `json:"protocols"`
+	Ciphers               string `json:"ciphers"`
+	SessionTimeout        string `json:"session-timeout"`
+	SessionTickets        *bool  `json:"session-tickets"`
+	HSTS                  *bool  `json:"hsts"`
+	HSTSMaxAge            *int   `json:"hsts-max-age"`
+	HSTSIncludeSubdomains *bool  `json:"hsts-include-subdomains"`
+	HSTSPreload           *bool  `json:"hsts-preload"`
+	DisableHTTP           bool   `json:"disable-http"`
+}
+
+// Policy is the tls policy rendered into a server
+type Policy struct {
+	Protocols      string
+	Ciphers        string
+	SessionTimeout string
+	SessionTickets bool
+	// HSTS is the value of the Strict-Transport-Security header, empty when it is not sent
+	HSTS        string
+	DisableHTTP bool
+}
+
+type sslPolicy struct {
+	r resolver.Resolver
+}
+
+func NewParser(r resolver.Resolver) parser.IngressAnnotation {
+	return &sslPolicy{}
+}
+
+func (s *sslPolicy) Parse(ing *ingressv1.Ingress) (interface{}, error) {
+	config := &Config{}
+
+	for name, field := range map[string]*string{
+		sslProtocolsAnnotation:      &config.Protocols,
+		sslCiphersAnnotation:        &config.Ciphers,
+		sslSessionTimeoutAnnotation: &config.SessionTimeout,
+	} {
+		val, err := parser.GetStringAnnotation(name, ing, sslPolicyAnnotations.Annotations)
+		if err != nil {
+			if errors.IsInvalidAnnotationsContentError(err) {
+				return nil, err
+			}
+			continue
+		}
+		*field = val
+	}
+	config.Protocols = strings.Join(strings.Fields(config.Protocols), " ")
+
+	for name, field := range map[string]**bool{
+		sslSessionTicketsAnnotation:     &config.SessionTickets,
+		hstsAnnotation:                  &config.HSTS,
+		hstsIncludeSubdomainsAnnotation: &config.HSTSIncludeSubdomains,
+		hstsPreloadAnnotation:           &config.HSTSPreload,
+	} {
+		val, err := parser.GetBoolAnnotations(name, ing, sslPolicyAnnotations.Annotations)
+		if err != nil {
+			continue
+		}
+		*field = &val
+	}
+
+	maxAge, err := parser.GetIntAnnotation(hstsMaxAgeAnnotation, ing, sslPolicyAnnotations.Annotations)
+	if err != nil {
+		if errors.IsInvalidAnnotationsContentError(err) {
+			return nil, err
+		}
+	} else {
+		config.HSTSMaxAge = &maxAge
+	}
+
+	config.DisableHTTP, _ = parser.GetBoolAnnotations(disableHTTPAnnotation, ing, sslPolicyAnnotations.Annotations)
+	if config.DisableHTTP && len(ing.Spec.TLS) == 0 {
+		msg := fmt.Sprintf("annotation %s requires the tls of the ingress, ingress name %s", disableHTTPAnnotation, ing.Name)
+		return nil, errors.NewNotSatisfiableError(msg)
+	}
+
+	return config, nil
+}
+
+func (s *sslPolicy) Validate(anns map[string]string) error {
+	return parser.CheckAnnotations(anns, sslPolicyAnnotations.Annotations)
+}
+
+// Merge returns the policy of the hosts of the ingress, the settings of g apply unless the ingress overrides them
+func (c Config) Merge(g *config.Global) Policy {
+	p := Policy{
+		Protocols:      pick(c.Protocols, g.SSLProtocols),
+		Ciphers:        pick(c.Ciphers, g.SSLCiphers),
+		SessionTimeout: pick(c.SessionTimeout, g.SSLSessionTimeout),
+		SessionTickets: pickBool(c.SessionTickets, g.SSLSessionTickets),
+		DisableHTTP:    c.DisableHTTP,
+	}
+
+	if !pickBool(c.HSTS, g.HSTS) {
+		return p
+	}
+
+	maxAge := g.HSTSMaxAge
+	if c.HSTSMaxAge != nil {
+		maxAge = *c.HSTSMaxAge
+	}
+	p.HSTS = fmt.Sprintf("max-age=%d", maxAge)
+	if pickBool(c.HSTSIncludeSubdomains, g.HSTSIncludeSubdomains) {
+		p.HSTS += "; includeSubDomains"
+	}
+	if pickBool(c.HSTSPreload, g.HSTSPreload) {
+		p.HSTS += "; preload"
+	}
+
+	return p
+}
+
+func pick(val, def string) string {
+	if val == "" {
+		return def
+	}
+
+	return val
+}
+
+func pickBool(val *bool, def bool) bool {
+	if val == nil {
+		return def
+	}
+
+	return *val
+}
@@ -0,0 +1,156 @@
+package sslpolicy
+
+import (
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations/testutil"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/config"
+	netv1 "k8s.io/api/networking/v1"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	yes, no, maxAge := true, false, 600
+
+	tests := []struct {
+		name    string
+		anns    map[string]string
+		tls     bool
+		want    *Config
+		wantErr bool
+	}{
+		{
+			name: "configmap settings",
+			anns: map[string]string{},
+			want: &Config{},
+		},
+		{
+			name: "policy",
+			anns: map[string]string{
+				sslProtocolsAnnotation:          "TLSv1.2   TLSv1.3",
+				sslCiphersAnnotation:            "ECDHE-RSA-AES128-GCM-SHA256:!aNULL",
+				sslSessionTimeoutAnnotation:     "1h",
+				sslSessionTicketsAnnotation:     "false",
+				hstsAnnotation:                  "true",
+				hstsMaxAgeAnnotation:            "600",
+				hstsIncludeSubdomainsAnnotation: "true",
+				disableHTTPAnnotation:           "true",
+			},
+			tls: true,
+			want: &Config{
+				Protocols:             "TLSv1.2 TLSv1.3",
+				Ciphers:               "ECDHE-RSA-AES128-GCM-SHA256:!aNULL",
+				SessionTimeout:        "1h",
+				SessionTickets:        &no,
+				HSTS:                  &yes,
+				HSTSMaxAge:            &maxAge,
+				HSTSIncludeSubdomains: &yes,
+				DisableHTTP:           true,
+			},
+		},
+		{
+			name: "invalid bool is not set",
+			anns: map[string]string{hstsPreloadAnnotation: "yes"},
+			want: &Config{},
+		},
+		{
+			name:    "unknown protocol",
+			anns:    map[string]string{sslProtocolsAnnotation: "TLSv1.2 SSLv3"},
+			wantErr: true,
+		},
+		{
+			name:    "ciphers with a directive",
+			anns:    map[string]string{sslCiphersAnnotation: "HIGH; ssl_verify_client off"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid session timeout",
+			anns:    map[string]string{sslSessionTimeoutAnnotation: "1 hour"},
+			wantErr: true,
+		},
+		{
+			name:    "max age out of range",
+			anns:    map[string]string{hstsMaxAgeAnnotation: "-1"},
+			wantErr: true,
+		},
+		{
+			name:    "disable http without tls",
+			anns:    map[string]string{disableHTTPAnnotation: "true"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ing := testutil.Ingress(tt.anns)
+			if tt.tls {
+				ing.Spec.TLS = []netv1.IngressTLS{{Hosts: []string{"a.com"}, SecretName: "a-tls"}}
+			}
+
+			got, err := NewParser(nil).Parse(ing)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMerge(t *testing.T) {
+	yes, no, maxAge := true, false, 600
+	g := &config.Global{
+		SSLProtocols:      "TLSv1.2 TLSv1.3",
+		SSLCiphers:        "HIGH",
+		SSLSessionTimeout: "10m",
+		HSTS:              true,
+		HSTSMaxAge:        15768000,
+	}
+
+	tests := []struct {
+		name   string
+		config Config
+		want   Policy
+	}{
+		{
+			name:   "configmap settings",
+			config: Config{},
+			want:   Policy{Protocols: "TLSv1.2 TLSv1.3", Ciphers: "HIGH", SessionTimeout: "10m", HSTS: "max-age=15768000"},
+		},
+		{
+			name: "overrides",
+			config: Config{
+				Protocols:             "TLSv1.3",
+				SessionTickets:        &yes,
+				HSTSMaxAge:            &maxAge,
+				HSTSIncludeSubdomains: &yes,
+				HSTSPreload:           &yes,
+				DisableHTTP:           true,
+			},
+			want: Policy{
+				Protocols:      "TLSv1.3",
+				Ciphers:        "HIGH",
+				SessionTimeout: "10m",
+				SessionTickets: true,
+				HSTS:           "max-age=600; includeSubDomains; preload",
+				DisableHTTP:    true,
+			},
+		},
+		{
+			name:   "hsts disabled",
+			config: Config{HSTS: &no, HSTSPreload: &yes},
+			want:   Policy{Protocols: "TLSv1.2 TLSv1.3", Ciphers: "HIGH", SessionTimeout: "10m"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.config.Merge(g); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Merge() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
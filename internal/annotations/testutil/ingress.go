@@ -0,0 +1,18 @@
+// Package testutil holds the fixtures shared by the tests of the annotation parsers
+package testutil
+
+import (
+	ingressv1 "github.com/Lxb921006/ingress-nginx-kubebuilder/api/v1"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations/parser"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Ingress returns the ingress demo of the default namespace, the names of anns are prefixed with the annotations prefix
+func Ingress(anns map[string]string) *ingressv1.Ingress {
+	prefixed := make(map[string]string, len(anns))
+	for name, val := range anns {
+		prefixed[parser.GetAnnotationWithPrefix(name)] = val
+	}
+
+	return &ingressv1.Ingress{ObjectMeta: metav1.ObjectMeta{Name: "demo", Namespace: "default", Annotations: prefixed}}
+}
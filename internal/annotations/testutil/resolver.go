@@ -0,0 +1,38 @@
+package testutil
+
+import (
+	"fmt"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations/resolver"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Resolver resolves the secrets and services of the namespace of Ingress by name, the methods
+// not needed by the parsers are left to the nil embedded Resolver
+type Resolver struct {
+	resolver.Resolver
+	Secrets  map[string]*corev1.Secret
+	Services map[string]*corev1.Service
+	// DefaultService is the name of the service of spec.defaultBackend
+	DefaultService string
+}
+
+func (r Resolver) GetSecret(key client.ObjectKey) (*corev1.Secret, error) {
+	if secret, ok := r.Secrets[key.Name]; ok {
+		return secret, nil
+	}
+
+	return nil, fmt.Errorf("secret %s not found", key)
+}
+
+func (r Resolver) GetService(name string) (*corev1.Service, error) {
+	if svc, ok := r.Services[name]; ok {
+		return svc, nil
+	}
+
+	return nil, fmt.Errorf("service %s not found", name)
+}
+
+func (r Resolver) GetDefaultService() (*corev1.Service, error) {
+	return r.GetService(r.DefaultService)
+}
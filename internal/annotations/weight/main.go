@@ -7,6 +7,7 @@ import (
 	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations/resolver"
 	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/errors"
 	"k8s.io/klog/v2"
+	"strconv"
 	"strings"
 )
 
@@ -32,10 +33,35 @@ type Translate struct {
 }
 
 type BackendWeight struct {
-	SvcList   []string `json:"svc-list"`
-	Upstream  string   `json:"upstream"`
-	UseWeight bool     `json:"weight"`
-	SetWeight string   `json:"set-weight"`
+	SvcList   []string           `json:"svc-list"`
+	Services  []WeightedService  `json:"services"`
+	Endpoints []WeightedEndpoint `json:"endpoints"`
+	Upstream  string             `json:"upstream"`
+	UseWeight bool               `json:"weight"`
+	SetWeight string             `json:"set-weight"`
+}
+
+// WeightedService is one `svc-name:weight=N` entry of set-weight
+type WeightedService struct {
+	Name      string `json:"name"`
+	NameSpace string `json:"name_space"`
+	Port      int32  `json:"port"`
+	Weight    int    `json:"weight"`
+}
+
+// WeightedEndpoint is one server of the weighted upstream, a zero weight marks it down
+type WeightedEndpoint struct {
+	Address string `json:"address"`
+	Weight  int    `json:"weight"`
+}
+
+// String formats the endpoint as the parameters of a server directive
+func (e WeightedEndpoint) String() string {
+	if e.Weight == 0 {
+		return e.Address + " down"
+	}
+
+	return fmt.Sprintf("%s weight=%d", e.Address, e.Weight)
 }
 
 type weight struct {
@@ -119,6 +145,14 @@ func (r *weight) check(ing *ingressv1.Ingress, config *BackendWeight) error {
 				return errors.NewInvalidAnnotationsContentError(setWeightAnnotation, config.SetWeight)
 			}
 
+			w, _ := strconv.Atoi(strings.TrimPrefix(val[1], "weight="))
+			config.Services = append(config.Services, WeightedService{
+				Name:      svc.Name,
+				NameSpace: svc.Namespace,
+				Port:      svcPort,
+				Weight:    w,
+			})
+
 			if r.inspectWeightVal(val[1]).Data != "" {
 				val[1] = r.inspectWeightVal(val[1]).Data
 			}
@@ -6,10 +6,17 @@ const (
 	ServerTmpl     = "/rootfs/etc/nginx/template/server.tmpl"
 	MainServerTmpl = "/rootfs/etc/nginx/template/mainServer.tmpl"
 	DefaultTmpl    = "/rootfs/etc/nginx/template/defaultBackend.tmpl"
+	StreamTmpl     = "/rootfs/etc/nginx/template/stream.tmpl"
+	StreamDir      = "/etc/nginx/stream.d"
+	StreamConf     = "services"
 	SslPath        = "/etc/nginx/ssl"
 	TlsCrt         = "tls.crt"
 	TlsKey         = "tls.key"
 	Pid            = "/var/run/nginx.pid"
 	Bin            = "/usr/sbin/nginx"
 	MainConf       = "/etc/nginx/nginx.conf"
+	StatusPort     = 10246
+	StatusPath     = "/nginx_status"
+	VTSPath        = "/nginx_vts"
+	BackendsPath   = "/configuration/backends"
 )
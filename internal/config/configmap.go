@@ -0,0 +1,248 @@
+package config
+
+import (
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations/parser"
+	kerr "github.com/Lxb921006/ingress-nginx-kubebuilder/internal/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog/v2"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	mimeTypeRegex  = regexp.MustCompile(`^[\w.+*-]+/[\w.+*-]+$`)
+	sslCipherRegex = regexp.MustCompile(`^[\w!+:@.-]+$`)
+	nameRegex      = regexp.MustCompile(`^[a-z0-9]([-a-z0-9.]*[a-z0-9])?$`)
+	portRegex      = regexp.MustCompile(`^([1-9]\d{0,4}|[a-z]([-a-z0-9]*[a-z0-9])?)$`)
+
+	sslProtocols   = sets.New("SSLv2", "SSLv3", "TLSv1", "TLSv1.1", "TLSv1.2", "TLSv1.3")
+	errorLogLevels = sets.New("debug", "info", "notice", "warn", "error", "crit", "alert", "emerg")
+)
+
+// configMapKeys validates the value of every key of the global ConfigMap and applies it to the settings
+var configMapKeys = map[string]func(g *Global, key, val string) error{
+	"worker-processes": func(g *Global, key, val string) error {
+		if val != "auto" {
+			if _, err := parseInt(key, val, 1, 1024); err != nil {
+				return err
+			}
+		}
+		g.WorkerProcesses = val
+		return nil
+	},
+	"worker-connections": intKey(func(g *Global) *int { return &g.WorkerConnections }, 1, 1048576),
+	"worker-shutdown-timeout": func(g *Global, key, val string) error {
+		if parser.ValidateTime(val) != nil {
+			return kerr.NewInvalidConfigurationError(key, val)
+		}
+		g.WorkerShutdownTimeout = val
+		return nil
+	},
+	"keep-alive":          intKey(func(g *Global) *int { return &g.KeepAlive }, 0, 86400),
+	"keep-alive-requests": intKey(func(g *Global) *int { return &g.KeepAliveRequests }, 1, 1000000),
+	"gzip":                boolKey(func(g *Global) *bool { return &g.UseGzip }),
+	"gzip-level":          intKey(func(g *Global) *int { return &g.GzipLevel }, 1, 9),
+	"gzip-types": func(g *Global, key, val string) error {
+		types := strings.Fields(val)
+		if len(types) == 0 {
+			return kerr.NewInvalidConfigurationError(key, val)
+		}
+		for _, t := range types {
+			if !mimeTypeRegex.MatchString(t) {
+				return kerr.NewInvalidConfigurationError(key, val)
+			}
+		}
+		g.GzipTypes = strings.Join(types, " ")
+		return nil
+	},
+	"log-format-upstream": func(g *Global, key, val string) error {
+		// rendered inside a single quoted string
+		if val == "" || strings.ContainsAny(val, "'\n\\") {
+			return kerr.NewInvalidConfigurationError(key, val)
+		}
+		g.LogFormatUpstream = val
+		return nil
+	},
+	"error-log-level": func(g *Global, key, val string) error {
+		if !errorLogLevels.Has(val) {
+			return kerr.NewInvalidConfigurationError(key, val)
+		}
+		g.ErrorLogLevel = val
+		return nil
+	},
+	"proxy-body-size": func(g *Global, key, val string) error {
+		if parser.ValidateSize(val) != nil {
+			return kerr.NewInvalidConfigurationError(key, val)
+		}
+		g.ProxyBodySize = val
+		return nil
+	},
+	"ssl-protocols": func(g *Global, key, val string) error {
+		protocols := strings.Fields(val)
+		if len(protocols) == 0 || !sslProtocols.HasAll(protocols...) {
+			return kerr.NewInvalidConfigurationError(key, val)
+		}
+		g.SSLProtocols = strings.Join(protocols, " ")
+		return nil
+	},
+	"ssl-ciphers": func(g *Global, key, val string) error {
+		if !sslCipherRegex.MatchString(val) {
+			return kerr.NewInvalidConfigurationError(key, val)
+		}
+		g.SSLCiphers = val
+		return nil
+	},
+	"ssl-session-timeout": func(g *Global, key, val string) error {
+		if parser.ValidateTime(val) != nil {
+			return kerr.NewInvalidConfigurationError(key, val)
+		}
+		g.SSLSessionTimeout = val
+		return nil
+	},
+	"ssl-session-tickets":     boolKey(func(g *Global) *bool { return &g.SSLSessionTickets }),
+	"hsts":                    boolKey(func(g *Global) *bool { return &g.HSTS }),
+	"hsts-max-age":            intKey(func(g *Global) *int { return &g.HSTSMaxAge }, 0, 315360000),
+	"hsts-include-subdomains": boolKey(func(g *Global) *bool { return &g.HSTSIncludeSubdomains }),
+	"hsts-preload":            boolKey(func(g *Global) *bool { return &g.HSTSPreload }),
+	"allowlist-source-range":  cidrListKey(func(g *Global) *[]string { return &g.AllowList }),
+	"denylist-source-range":   cidrListKey(func(g *Global) *[]string { return &g.DenyList }),
+	"use-forwarded-headers":   boolKey(func(g *Global) *bool { return &g.UseForwardedHeaders }),
+	"forwarded-for-header": func(g *Global, key, val string) error {
+		if !parser.IsHeaderName(val) {
+			return kerr.NewInvalidConfigurationError(key, val)
+		}
+		g.ForwardedForHeader = val
+		return nil
+	},
+	"use-proxy-protocol": boolKey(func(g *Global) *bool { return &g.UseProxyProtocol }),
+	"proxy-real-ip-cidr": cidrListKey(func(g *Global) *[]string { return &g.ProxyRealIPCIDR }),
+	"proxy-set-headers":  configMapRefKey(func(g *Global) *string { return &g.ProxySetHeaders }),
+	"add-headers":        configMapRefKey(func(g *Global) *string { return &g.AddHeaders }),
+}
+
+// configMapAliases maps the former names of keys to their current one, which takes precedence when both are set
+var configMapAliases = map[string]string{
+	"use-gzip": "gzip",
+}
+
+// ParseConfigMap applies the data of the global ConfigMap to a copy of base. Unknown keys are ignored,
+// an invalid value rejects the whole ConfigMap so that nginx keeps its current settings.
+func ParseConfigMap(base *Global, data map[string]string) (*Global, error) {
+	g := *base
+
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		name := key
+		if current, ok := configMapAliases[key]; ok {
+			if _, ok := data[current]; ok {
+				klog.Warningf("key %s of the configmap is ignored, %s is set", key, current)
+				continue
+			}
+			name = current
+		}
+
+		apply, ok := configMapKeys[name]
+		if !ok {
+			klog.Warningf("unknown key %s of the configmap is ignored", key)
+			continue
+		}
+
+		if err := apply(&g, key, strings.TrimSpace(data[key])); err != nil {
+			return nil, err
+		}
+	}
+
+	// the client address must only be taken from trusted proxies
+	if (g.UseForwardedHeaders || g.UseProxyProtocol) && len(g.ProxyRealIPCIDR) == 0 {
+		return nil, kerr.NewInvalidConfigurationError("proxy-real-ip-cidr", "")
+	}
+
+	return &g, nil
+}
+
+func intKey(field func(g *Global) *int, min, max int) func(g *Global, key, val string) error {
+	return func(g *Global, key, val string) error {
+		i, err := parseInt(key, val, min, max)
+		if err != nil {
+			return err
+		}
+		*field(g) = i
+		return nil
+	}
+}
+
+func boolKey(field func(g *Global) *bool) func(g *Global, key, val string) error {
+	return func(g *Global, key, val string) error {
+		b, err := strconv.ParseBool(val)
+		if err != nil {
+			return kerr.NewInvalidConfigurationError(key, val)
+		}
+		*field(g) = b
+		return nil
+	}
+}
+
+// cidrListKey parses a comma separated list of ip addresses and cidrs, e.g: 10.0.0.0/8,2001:db8::1
+func cidrListKey(field func(g *Global) *[]string) func(g *Global, key, val string) error {
+	return func(g *Global, key, val string) error {
+		cidrs := sets.New[string]()
+		for _, alias := range strings.Split(val, ",") {
+			alias = strings.TrimSpace(alias)
+			if alias == "" {
+				continue
+			}
+			cidr, err := parser.ParseCIDR(alias)
+			if err != nil {
+				return kerr.NewInvalidConfigurationError(key, alias)
+			}
+			cidrs.Insert(cidr)
+		}
+		*field(g) = sets.List(cidrs)
+		return nil
+	}
+}
+
+// configMapRefKey parses the namespace/name of a ConfigMap, e.g: ingress-nginx/custom-headers
+func configMapRefKey(field func(g *Global) *string) func(g *Global, key, val string) error {
+	return func(g *Global, key, val string) error {
+		namespace, name, ok := strings.Cut(val, "/")
+		if !ok || !nameRegex.MatchString(namespace) || !nameRegex.MatchString(name) {
+			return kerr.NewInvalidConfigurationError(key, val)
+		}
+		*field(g) = val
+		return nil
+	}
+}
+
+// ParseHeaders validates the data of the ConfigMap of the headers of key, the request headers set by
+// the controller can not be changed
+func ParseHeaders(key string, data map[string]string, request bool) (map[string]string, error) {
+	headers, err := parser.ParseHeaders(data)
+	if err != nil {
+		return nil, kerr.NewInvalidConfigurationError(key, err.Error())
+	}
+
+	for name := range headers {
+		if request && parser.IsControllerHeader(name) {
+			return nil, kerr.NewInvalidConfigurationError(key, name)
+		}
+	}
+
+	return headers, nil
+}
+
+func parseInt(key, val string, min, max int) (int, error) {
+	i, err := strconv.Atoi(val)
+	if err != nil || i < min || i > max {
+		return 0, kerr.NewInvalidConfigurationError(key, val)
+	}
+
+	return i, nil
+}
@@ -0,0 +1,313 @@
+package config
+
+import (
+	kerr "github.com/Lxb921006/ingress-nginx-kubebuilder/internal/errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseConfigMap(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    map[string]string
+		want    func(g *Global)
+		wantErr bool
+	}{
+		{
+			name: "empty",
+			data: map[string]string{},
+			want: func(g *Global) {},
+		},
+		{
+			name: "settings",
+			data: map[string]string{
+				"worker-processes":        "auto",
+				"worker-connections":      "1024",
+				"worker-shutdown-timeout": "10s",
+				"keep-alive":              "0",
+				"gzip":                    "true",
+				"gzip-level":              "5",
+				"gzip-types":              " text/html   application/json ",
+				"error-log-level":         "warn",
+				"proxy-body-size":         "8m",
+				"ssl-protocols":           "TLSv1.3",
+			},
+			want: func(g *Global) {
+				g.WorkerProcesses = "auto"
+				g.WorkerConnections = 1024
+				g.WorkerShutdownTimeout = "10s"
+				g.KeepAlive = 0
+				g.UseGzip = true
+				g.GzipLevel = 5
+				g.GzipTypes = "text/html application/json"
+				g.ErrorLogLevel = "warn"
+				g.ProxyBodySize = "8m"
+				g.SSLProtocols = "TLSv1.3"
+			},
+		},
+		{
+			name: "values are trimmed",
+			data: map[string]string{"worker-processes": " 8\n"},
+			want: func(g *Global) { g.WorkerProcesses = "8" },
+		},
+		{
+			name: "unknown key is ignored",
+			data: map[string]string{"no-such-key": "1"},
+			want: func(g *Global) {},
+		},
+		{
+			name:    "worker processes out of range",
+			data:    map[string]string{"worker-processes": "0"},
+			wantErr: true,
+		},
+		{
+			name:    "worker connections not a number",
+			data:    map[string]string{"worker-connections": "many"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid time",
+			data:    map[string]string{"worker-shutdown-timeout": "10 s"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid bool",
+			data:    map[string]string{"gzip": "yes"},
+			wantErr: true,
+		},
+		{
+			name: "use-gzip is an alias of gzip",
+			data: map[string]string{"use-gzip": "true"},
+			want: func(g *Global) { g.UseGzip = true },
+		},
+		{
+			name: "gzip takes precedence over its alias",
+			data: map[string]string{"gzip": "false", "use-gzip": "true"},
+			want: func(g *Global) {},
+		},
+		{
+			name:    "invalid alias value",
+			data:    map[string]string{"use-gzip": "yes"},
+			wantErr: true,
+		},
+		{
+			name:    "gzip level out of range",
+			data:    map[string]string{"gzip-level": "10"},
+			wantErr: true,
+		},
+		{
+			name:    "empty gzip types",
+			data:    map[string]string{"gzip-types": " "},
+			wantErr: true,
+		},
+		{
+			name:    "invalid gzip type",
+			data:    map[string]string{"gzip-types": "text/html;gzip"},
+			wantErr: true,
+		},
+		{
+			name:    "log format breaks out of its quotes",
+			data:    map[string]string{"log-format-upstream": "$remote_addr'; access_log off; '"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown error log level",
+			data:    map[string]string{"error-log-level": "verbose"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid size",
+			data:    map[string]string{"proxy-body-size": "8mb"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown ssl protocol",
+			data:    map[string]string{"ssl-protocols": "TLSv1.2 TLSv1.4"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid ssl ciphers",
+			data:    map[string]string{"ssl-ciphers": "HIGH; include /etc/passwd"},
+			wantErr: true,
+		},
+		{
+			name:    "an invalid key rejects the valid ones",
+			data:    map[string]string{"worker-processes": "2", "keep-alive": "-1"},
+			wantErr: true,
+		},
+		{
+			name: "source ranges are canonical and unique",
+			data: map[string]string{
+				"allowlist-source-range": "10.0.0.1/8, 10.0.0.0/8,,2001:db8::1",
+				"denylist-source-range":  "192.168.1.10",
+			},
+			want: func(g *Global) {
+				g.AllowList = []string{"10.0.0.0/8", "2001:db8::1"}
+				g.DenyList = []string{"192.168.1.10"}
+			},
+		},
+		{
+			name:    "invalid source range",
+			data:    map[string]string{"allowlist-source-range": "10.0.0.0/8,10.0.0.300"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid source range prefix",
+			data:    map[string]string{"denylist-source-range": "10.0.0.0/33"},
+			wantErr: true,
+		},
+		{
+			name: "forwarded headers from trusted proxies",
+			data: map[string]string{
+				"use-forwarded-headers": "true",
+				"forwarded-for-header":  "X-Real-Client",
+				"proxy-real-ip-cidr":    "10.0.0.0/8",
+			},
+			want: func(g *Global) {
+				g.UseForwardedHeaders = true
+				g.ForwardedForHeader = "X-Real-Client"
+				g.ProxyRealIPCIDR = []string{"10.0.0.0/8"}
+			},
+		},
+		{
+			name:    "forwarded headers without trusted proxies",
+			data:    map[string]string{"use-forwarded-headers": "true"},
+			wantErr: true,
+		},
+		{
+			name:    "proxy protocol without trusted proxies",
+			data:    map[string]string{"use-proxy-protocol": "true", "proxy-real-ip-cidr": ""},
+			wantErr: true,
+		},
+		{
+			name:    "invalid forwarded for header",
+			data:    map[string]string{"forwarded-for-header": "X-Real Client"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid real ip cidr",
+			data:    map[string]string{"use-proxy-protocol": "true", "proxy-real-ip-cidr": "proxy.local"},
+			wantErr: true,
+		},
+		{
+			name: "tls sessions and hsts",
+			data: map[string]string{
+				"ssl-session-timeout":     "1d",
+				"ssl-session-tickets":     "true",
+				"hsts":                    "true",
+				"hsts-max-age":            "600",
+				"hsts-include-subdomains": "true",
+				"hsts-preload":            "true",
+			},
+			want: func(g *Global) {
+				g.SSLSessionTimeout = "1d"
+				g.SSLSessionTickets = true
+				g.HSTS = true
+				g.HSTSMaxAge = 600
+				g.HSTSIncludeSubdomains = true
+				g.HSTSPreload = true
+			},
+		},
+		{
+			name:    "invalid session timeout",
+			data:    map[string]string{"ssl-session-timeout": "1 day"},
+			wantErr: true,
+		},
+		{
+			name:    "hsts max age out of range",
+			data:    map[string]string{"hsts-max-age": "-1"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base := NewGlobal()
+			got, err := ParseConfigMap(base, tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseConfigMap() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if !kerr.IsInvalidConfigurationError(err) {
+					t.Errorf("ParseConfigMap() error = %v, want an invalid configuration error", err)
+				}
+				return
+			}
+
+			want := NewGlobal()
+			tt.want(want)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("ParseConfigMap() = %+v, want %+v", got, want)
+			}
+			if !reflect.DeepEqual(base, NewGlobal()) {
+				t.Errorf("ParseConfigMap() changed the base settings")
+			}
+		})
+	}
+}
+
+func TestParseHeaders(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    map[string]string
+		request bool
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name:    "canonical names",
+			data:    map[string]string{"x-env": "prod", "X-CLIENT": "$remote_addr"},
+			request: true,
+			want:    map[string]string{"X-Env": "prod", "X-Client": "$remote_addr"},
+		},
+		{
+			name: "empty",
+			data: map[string]string{},
+			want: map[string]string{},
+		},
+		{
+			name: "controller header on the responses",
+			data: map[string]string{"X-Forwarded-For": "1.1.1.1"},
+			want: map[string]string{"X-Forwarded-For": "1.1.1.1"},
+		},
+		{
+			name:    "controller header on the requests",
+			data:    map[string]string{"x-forwarded-for": "1.1.1.1"},
+			request: true,
+			wantErr: true,
+		},
+		{
+			name:    "invalid name",
+			data:    map[string]string{"X Env": "prod"},
+			wantErr: true,
+		},
+		{
+			name:    "value breaks out of its quotes",
+			data:    map[string]string{"X-Env": `prod"; return 200 "`},
+			wantErr: true,
+		},
+		{
+			name:    "value with a newline",
+			data:    map[string]string{"X-Env": "prod\nX-Other: 1"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseHeaders("proxy-set-headers", tt.data, tt.request)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseHeaders() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if !kerr.IsInvalidConfigurationError(err) {
+					t.Errorf("ParseHeaders() error = %v, want an invalid configuration error", err)
+				}
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseHeaders() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
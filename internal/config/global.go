@@ -0,0 +1,97 @@
+package config
+
+import "sync"
+
+// Global holds the controller wide settings rendered into nginx.tmpl. The flags of the controller set
+// StatusPort, EnableVTS, DynamicUpstreams, AllowSnippetAnnotations and Stream, the global ConfigMap sets the rest.
+type Global struct {
+	StatusPort              int
+	EnableVTS               bool
+	DynamicUpstreams        bool
+	AllowSnippetAnnotations bool
+	// Stream renders the stream block of the tcp and udp services
+	Stream bool
+
+	WorkerProcesses       string
+	WorkerConnections     int
+	WorkerShutdownTimeout string
+	KeepAlive             int
+	KeepAliveRequests     int
+	UseGzip               bool
+	GzipLevel             int
+	GzipTypes             string
+	LogFormatUpstream     string
+	ErrorLogLevel         string
+	ProxyBodySize         string
+	SSLProtocols          string
+	SSLCiphers            string
+	SSLSessionTimeout     string
+	SSLSessionTickets     bool
+	HSTS                  bool
+	HSTSMaxAge            int
+	HSTSIncludeSubdomains bool
+	HSTSPreload           bool
+
+	// AllowList and DenyList apply to every location, see server.tmpl for the precedence
+	AllowList []string
+	DenyList  []string
+	// the client address is read from ForwardedForHeader or the PROXY protocol when the request
+	// comes from ProxyRealIPCIDR
+	UseForwardedHeaders bool
+	ForwardedForHeader  string
+	UseProxyProtocol    bool
+	ProxyRealIPCIDR     []string
+
+	// ProxySetHeaders and AddHeaders are the namespace/name of the ConfigMaps of the headers set on the
+	// requests to the backends and on the responses of every location, their data is loaded into
+	// RequestHeaders and ResponseHeaders
+	ProxySetHeaders string
+	AddHeaders      string
+	RequestHeaders  map[string]string
+	ResponseHeaders map[string]string
+}
+
+// NewGlobal returns the settings used when the global ConfigMap does not override them
+func NewGlobal() *Global {
+	return &Global{
+		StatusPort:            StatusPort,
+		WorkerProcesses:       "4",
+		WorkerConnections:     16384,
+		WorkerShutdownTimeout: "240s",
+		KeepAlive:             65,
+		KeepAliveRequests:     1000,
+		GzipLevel:             1,
+		GzipTypes:             "application/javascript application/json application/xml text/css text/javascript text/plain text/xml",
+		LogFormatUpstream: `$remote_addr - $remote_user [$time_local] "$request" ` +
+			`$status $body_bytes_sent "$http_referer" "$http_user_agent" "$http_x_forwarded_for"`,
+		ErrorLogLevel: "notice",
+		ProxyBodySize: "1m",
+		SSLProtocols:  "TLSv1.2 TLSv1.3",
+		SSLCiphers: "ECDHE-ECDSA-AES128-GCM-SHA256:ECDHE-RSA-AES128-GCM-SHA256:ECDHE-ECDSA-AES256-GCM-SHA384:" +
+			"ECDHE-RSA-AES256-GCM-SHA384:ECDHE-ECDSA-CHACHA20-POLY1305:ECDHE-RSA-CHACHA20-POLY1305",
+		SSLSessionTimeout: "10m",
+		HSTS:              true,
+		HSTSMaxAge:        15768000,
+
+		ForwardedForHeader: "X-Forwarded-For",
+	}
+}
+
+var (
+	globalMux = new(sync.RWMutex)
+	global    = NewGlobal()
+)
+
+// GetGlobal returns the settings currently rendered into nginx.tmpl
+func GetGlobal() *Global {
+	globalMux.RLock()
+	defer globalMux.RUnlock()
+	return global
+}
+
+// SetGlobal replaces the settings rendered into nginx.tmpl, the next render of nginx.conf picks them up
+func SetGlobal(g *Global) {
+	globalMux.Lock()
+	defer globalMux.Unlock()
+	global = g
+}
@@ -0,0 +1,100 @@
+package config
+
+import (
+	kerr "github.com/Lxb921006/ingress-nginx-kubebuilder/internal/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	ProtocolTCP = "tcp"
+	ProtocolUDP = "udp"
+)
+
+// reservedPorts are the ports of the http and status servers of nginx and the ones the metrics, health probe
+// and webhook servers of the manager listen on by default, ReservePorts adds the ones set by its flags
+var reservedPorts = sets.New(80, 443, StatusPort, 8080, 8081, 9443)
+
+// ReservePorts keeps the stream services off the ports the manager listens on
+func ReservePorts(ports ...int) {
+	reservedPorts.Insert(ports...)
+}
+
+// StreamService is an entry of the tcp or udp services ConfigMap, e.g: `5432: db/postgres:5432:PROXY`.
+// The first PROXY accepts the PROXY protocol from the clients, the second one sends it to the service.
+type StreamService struct {
+	Protocol    string
+	Port        int
+	Namespace   string
+	Service     string
+	ServicePort string
+	DecodeProxy bool
+	EncodeProxy bool
+	// Address is the dns name and port of the service, set once the service is found
+	Address string
+}
+
+// ParseStreamServices parses the data of the tcp or udp services ConfigMap, the services are sorted by port
+func ParseStreamServices(protocol string, data map[string]string) ([]StreamService, error) {
+	services := make([]StreamService, 0, len(data))
+	for key, val := range data {
+		port, err := parseInt(key, key, 1, 65535)
+		if err != nil || reservedPorts.Has(port) {
+			return nil, kerr.NewInvalidConfigurationError(key, key)
+		}
+
+		fields := strings.Split(strings.TrimSpace(val), ":")
+		namespace, name, ok := strings.Cut(fields[0], "/")
+		if !ok || !nameRegex.MatchString(namespace) || !nameRegex.MatchString(name) || len(fields) < 2 || len(fields) > 4 {
+			return nil, kerr.NewInvalidConfigurationError(key, val)
+		}
+
+		svc := StreamService{
+			Protocol:    protocol,
+			Port:        port,
+			Namespace:   namespace,
+			Service:     name,
+			ServicePort: fields[1],
+		}
+		if !portRegex.MatchString(svc.ServicePort) {
+			return nil, kerr.NewInvalidConfigurationError(key, val)
+		}
+		for i, proxy := range []*bool{&svc.DecodeProxy, &svc.EncodeProxy} {
+			if len(fields) <= i+2 {
+				break
+			}
+			if fields[i+2] != "PROXY" && fields[i+2] != "" {
+				return nil, kerr.NewInvalidConfigurationError(key, val)
+			}
+			*proxy = fields[i+2] == "PROXY"
+		}
+
+		// nginx only speaks the PROXY protocol over tcp
+		if protocol == ProtocolUDP && (svc.DecodeProxy || svc.EncodeProxy) {
+			return nil, kerr.NewInvalidConfigurationError(key, val)
+		}
+
+		services = append(services, svc)
+	}
+
+	sort.Slice(services, func(i, j int) bool {
+		return services[i].Port < services[j].Port
+	})
+
+	return services, nil
+}
+
+// Listen returns the parameters of the listen directives of the service
+func (s StreamService) Listen() string {
+	listen := strconv.Itoa(s.Port)
+	if s.Protocol == ProtocolUDP {
+		listen += " udp"
+	}
+	if s.DecodeProxy {
+		listen += " proxy_protocol"
+	}
+
+	return listen
+}
@@ -0,0 +1,175 @@
+package config
+
+import (
+	kerr "github.com/Lxb921006/ingress-nginx-kubebuilder/internal/errors"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestParseStreamServices(t *testing.T) {
+	ReservePorts(9090)
+
+	tests := []struct {
+		name     string
+		protocol string
+		data     map[string]string
+		want     []StreamService
+		wantErr  bool
+	}{
+		{
+			name:     "sorted by port",
+			protocol: ProtocolTCP,
+			data: map[string]string{
+				"5432": " db/postgres:5432 ",
+				"3306": "db/mysql:mysql",
+			},
+			want: []StreamService{
+				{Protocol: ProtocolTCP, Port: 3306, Namespace: "db", Service: "mysql", ServicePort: "mysql"},
+				{Protocol: ProtocolTCP, Port: 5432, Namespace: "db", Service: "postgres", ServicePort: "5432"},
+			},
+		},
+		{
+			name:     "proxy protocol",
+			protocol: ProtocolTCP,
+			data: map[string]string{
+				"9000": "default/a:80:PROXY",
+				"9001": "default/b:80::PROXY",
+				"9002": "default/c:80:PROXY:PROXY",
+			},
+			want: []StreamService{
+				{Protocol: ProtocolTCP, Port: 9000, Namespace: "default", Service: "a", ServicePort: "80", DecodeProxy: true},
+				{Protocol: ProtocolTCP, Port: 9001, Namespace: "default", Service: "b", ServicePort: "80", EncodeProxy: true},
+				{Protocol: ProtocolTCP, Port: 9002, Namespace: "default", Service: "c", ServicePort: "80", DecodeProxy: true, EncodeProxy: true},
+			},
+		},
+		{
+			name:     "udp",
+			protocol: ProtocolUDP,
+			data:     map[string]string{"53": "kube-system/kube-dns:53"},
+			want:     []StreamService{{Protocol: ProtocolUDP, Port: 53, Namespace: "kube-system", Service: "kube-dns", ServicePort: "53"}},
+		},
+		{
+			name:     "empty",
+			protocol: ProtocolTCP,
+			data:     map[string]string{},
+			want:     []StreamService{},
+		},
+		{
+			name:     "udp with proxy protocol",
+			protocol: ProtocolUDP,
+			data:     map[string]string{"53": "kube-system/kube-dns:53:PROXY"},
+			wantErr:  true,
+		},
+		{
+			name:     "port of the http servers",
+			protocol: ProtocolTCP,
+			data:     map[string]string{"443": "default/a:443"},
+			wantErr:  true,
+		},
+		{
+			name:     "status port",
+			protocol: ProtocolTCP,
+			data:     map[string]string{strconv.Itoa(StatusPort): "default/a:80"},
+			wantErr:  true,
+		},
+		{
+			name:     "port of the manager",
+			protocol: ProtocolTCP,
+			data:     map[string]string{"9443": "default/a:443"},
+			wantErr:  true,
+		},
+		{
+			name:     "port reserved by a flag of the manager",
+			protocol: ProtocolTCP,
+			data:     map[string]string{"9090": "default/a:80"},
+			wantErr:  true,
+		},
+		{
+			name:     "port out of range",
+			protocol: ProtocolTCP,
+			data:     map[string]string{"65536": "default/a:80"},
+			wantErr:  true,
+		},
+		{
+			name:     "port not a number",
+			protocol: ProtocolTCP,
+			data:     map[string]string{"postgres": "db/postgres:5432"},
+			wantErr:  true,
+		},
+		{
+			name:     "no namespace",
+			protocol: ProtocolTCP,
+			data:     map[string]string{"5432": "postgres:5432"},
+			wantErr:  true,
+		},
+		{
+			name:     "no service port",
+			protocol: ProtocolTCP,
+			data:     map[string]string{"5432": "db/postgres"},
+			wantErr:  true,
+		},
+		{
+			name:     "invalid service name",
+			protocol: ProtocolTCP,
+			data:     map[string]string{"5432": "db/postgres;:5432"},
+			wantErr:  true,
+		},
+		{
+			name:     "invalid service port",
+			protocol: ProtocolTCP,
+			data:     map[string]string{"5432": "db/postgres:0"},
+			wantErr:  true,
+		},
+		{
+			name:     "unknown option",
+			protocol: ProtocolTCP,
+			data:     map[string]string{"5432": "db/postgres:5432:SSL"},
+			wantErr:  true,
+		},
+		{
+			name:     "too many fields",
+			protocol: ProtocolTCP,
+			data:     map[string]string{"5432": "db/postgres:5432:PROXY:PROXY:PROXY"},
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseStreamServices(tt.protocol, tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseStreamServices() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if !kerr.IsInvalidConfigurationError(err) {
+					t.Errorf("ParseStreamServices() error = %v, want an invalid configuration error", err)
+				}
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseStreamServices() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStreamServiceListen(t *testing.T) {
+	tests := []struct {
+		name string
+		svc  StreamService
+		want string
+	}{
+		{name: "tcp", svc: StreamService{Protocol: ProtocolTCP, Port: 5432}, want: "5432"},
+		{name: "udp", svc: StreamService{Protocol: ProtocolUDP, Port: 53}, want: "53 udp"},
+		{name: "proxy protocol", svc: StreamService{Protocol: ProtocolTCP, Port: 9000, DecodeProxy: true, EncodeProxy: true}, want: "9000 proxy_protocol"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.svc.Listen(); got != tt.want {
+				t.Errorf("Listen() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package controller
+
+import (
+	"fmt"
+	ingressv1 "github.com/Lxb921006/ingress-nginx-kubebuilder/api/v1"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations/affinity"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations/weight"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/nginx"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog/v2"
+	"net"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"strconv"
+	"strings"
+)
+
+// upstream is an upstream block of the configuration file of an ingress. Every backend of the ingress
+// has one, even when the server block of its host is rendered by an older ingress sharing the host.
+type upstream struct {
+	Name     string
+	Service  string
+	Weighted bool
+	Servers  []weight.WeightedEndpoint
+	Affinity affinity.Config
+}
+
+// buildUpstreams returns the upstream blocks of the backends of an ingress
+func (n *NginxController) buildUpstreams(servers []*ingressv1.Server, anns *annotations.Ingress) ([]upstream, error) {
+	if weights := anns.Weight; weights.UseWeight {
+		return []upstream{{
+			Name:     weights.Upstream,
+			Service:  strings.TrimSuffix(weights.Upstream, "-"+n.ingress.Name+"-"+n.ingress.Namespace),
+			Weighted: true,
+			Servers:  weights.Endpoints,
+			Affinity: anns.Affinity,
+		}}, nil
+	}
+
+	var ups []upstream
+	names := sets.New[string]()
+	for _, s := range servers {
+		for _, b := range s.Paths {
+			name := upstreamName(b)
+			if names.Has(name) {
+				continue
+			}
+			names.Insert(name)
+
+			addresses := b.Endpoints
+			if len(addresses) == 0 {
+				svc, err := n.rr.GetService(b.Name)
+				if err != nil {
+					return nil, err
+				}
+				addresses = []string{serviceAddress(svc, b.Port)}
+			}
+
+			up := upstream{Name: name, Service: b.Name, Affinity: anns.Affinity}
+			for _, addr := range addresses {
+				up.Servers = append(up.Servers, weight.WeightedEndpoint{Address: addr, Weight: 1})
+			}
+			ups = append(ups, up)
+		}
+	}
+
+	return ups, nil
+}
+
+// upstreamName returns the name of the upstream block a backend proxies to
+func upstreamName(b *ingressv1.Backend) string {
+	if anns, ok := b.Annotations.(*annotations.Ingress); ok && anns.Weight.UseWeight {
+		return anns.Weight.Upstream
+	}
+
+	return b.Name + "-" + b.IngName + "-" + b.NameSpace
+}
+
+// pushBackends sends the current peers of the upstreams balanced by balancer.lua to nginx once the
+// configuration rendering them is loaded. The rendered upstreams do not contain the peers, so pod changes
+// never alter the generated configuration and nginx.Reload skips the reload.
+func (n *NginxController) pushBackends(cfg *configure) error {
+	owner := client.ObjectKeyFromObject(n.ingress).String()
+	if !cfg.DynamicUpstreams {
+		return nginx.DeleteBackends(owner)
+	}
+
+	backends := make([]nginx.Backend, 0, len(cfg.Upstreams))
+	for _, up := range cfg.Upstreams {
+		b := nginx.Backend{Name: up.Name}
+		for _, s := range up.Servers {
+			b.Endpoints = appendEndpoint(b.Endpoints, s.Address, s.Weight)
+		}
+		backends = append(backends, b)
+	}
+
+	if err := nginx.SetBackends(owner, backends); err != nil {
+		klog.ErrorS(err, fmt.Sprintf("fail to update the dynamic upstreams of ingress: %s, namespace: %s", n.ingress.Name, n.ingress.Namespace))
+		return err
+	}
+
+	klog.V(2).InfoS("dynamic upstreams updated", "ingress", n.ingress.Name, "namespace", n.ingress.Namespace, "upstreams", len(backends))
+
+	return nil
+}
+
+// appendEndpoint appends the peers of address, balancer.lua only takes ips so a host name is resolved here
+func appendEndpoint(endpoints []nginx.Endpoint, address string, weight int) []nginx.Endpoint {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		klog.Warningf("skip endpoint %s of a dynamic upstream: %v", address, err)
+		return endpoints
+	}
+
+	if net.ParseIP(host) != nil {
+		return append(endpoints, nginx.Endpoint{Address: host, Port: port, Weight: weight})
+	}
+
+	ips, err := net.LookupHost(host)
+	if err != nil {
+		klog.Warningf("skip endpoint %s of a dynamic upstream: %v", address, err)
+		return endpoints
+	}
+
+	for _, ip := range ips {
+		endpoints = append(endpoints, nginx.Endpoint{Address: ip, Port: port, Weight: weight})
+	}
+
+	return endpoints
+}
+
+// serviceAddress returns the ClusterIP and port of the service, or its DNS name for a headless service
+func serviceAddress(svc *corev1.Service, port int32) string {
+	host := svc.Spec.ClusterIP
+	if net.ParseIP(host) == nil {
+		host = fmt.Sprintf("%s.%s.svc", svc.Name, svc.Namespace)
+	}
+
+	return net.JoinHostPort(host, strconv.Itoa(int(port)))
+}
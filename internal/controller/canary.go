@@ -0,0 +1,73 @@
+package controller
+
+import (
+	"fmt"
+	ingressv1 "github.com/Lxb921006/ingress-nginx-kubebuilder/api/v1"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations/canary"
+	"hash/fnv"
+)
+
+// canaryRoute picks the upstream of a path between the primary ingress and its canary. It is rendered at
+// http level by the ingress owning the host, as a split_clients on the weight chained to maps on the
+// cookie and the header, and the location of the path proxies to the last variable of the chain.
+type canaryRoute struct {
+	Host    string
+	Path    string
+	Primary string
+	Canary  string
+
+	Weight      int
+	Cookie      string
+	Header      string
+	HeaderValue string
+
+	WeightVariable string
+	CookieVariable string
+	HeaderVariable string
+}
+
+func newCanaryRoute(host string, primary, backend *ingressv1.Backend, cfg *canary.Config) canaryRoute {
+	h := fnv.New32a()
+	h.Write([]byte(host + primary.Path))
+	base := fmt.Sprintf("canary_%08x", h.Sum32())
+
+	r := canaryRoute{
+		Host:           host,
+		Path:           primary.Path,
+		Primary:        upstreamName(primary),
+		Canary:         upstreamName(backend),
+		Weight:         cfg.Weight,
+		Cookie:         cfg.Cookie,
+		HeaderValue:    cfg.HeaderValue,
+		WeightVariable: base + "_weight",
+	}
+
+	if cfg.Cookie != "" {
+		r.CookieVariable = base + "_cookie"
+	}
+
+	if cfg.Header != "" {
+		r.Header = cfg.HeaderVariable()
+		r.HeaderVariable = base + "_header"
+	}
+
+	return r
+}
+
+// Variable returns the variable holding the upstream picked for a request
+func (r canaryRoute) Variable() string {
+	if r.HeaderVariable != "" {
+		return r.HeaderVariable
+	}
+
+	return r.CookieFallback()
+}
+
+// CookieFallback returns the variable used when the header does not pick an upstream
+func (r canaryRoute) CookieFallback() string {
+	if r.CookieVariable != "" {
+		return r.CookieVariable
+	}
+
+	return r.WeightVariable
+}
@@ -0,0 +1,176 @@
+package controller
+
+import (
+	"context"
+	errs "errors"
+	"fmt"
+	ingressv1 "github.com/Lxb921006/ingress-nginx-kubebuilder/api/v1"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/config"
+	kerr "github.com/Lxb921006/ingress-nginx-kubebuilder/internal/errors"
+	corev1 "k8s.io/api/core/v1"
+	netv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog/v2"
+	"reflect"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"strings"
+)
+
+// ConfigMapReconciler applies the global ConfigMap to nginx.conf and to the server blocks of every ingress
+type ConfigMapReconciler struct {
+	client.Client
+	Recorder record.EventRecorder
+	// ConfigMap is the namespace/name of the global ConfigMap
+	ConfigMap types.NamespacedName
+	// Base holds the settings of the flags and the defaults the ConfigMap overrides
+	Base *config.Global
+}
+
+//+kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch
+
+func (r *ConfigMapReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	var data map[string]string
+
+	cm := new(corev1.ConfigMap)
+	if err := r.Get(ctx, r.ConfigMap, cm); err != nil {
+		if !errors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+		klog.Infof("configmap %s not found, falling back to the default settings", r.ConfigMap)
+		cm = nil
+	} else {
+		data = cm.Data
+	}
+
+	g, err := config.ParseConfigMap(r.Base, data)
+	if err == nil {
+		err = r.loadHeaders(ctx, g)
+	}
+	if err != nil {
+		klog.ErrorS(err, fmt.Sprintf("fail to parse configmap %s, keeping the current settings", r.ConfigMap))
+		r.event(cm, corev1.EventTypeWarning, kerr.Reason(err), err.Error())
+		return ctrl.Result{}, nil
+	}
+
+	// the settings are valid, a failed render or nginx -t is retried with backoff until it passes
+	changed, err := r.apply(g)
+	if err != nil {
+		klog.ErrorS(err, fmt.Sprintf("fail to apply configmap %s, keeping the current settings", r.ConfigMap))
+		var testErr kerr.NginxTestError
+		if errs.As(err, &testErr) && testErr.RolledBack {
+			r.event(cm, corev1.EventTypeWarning, "RollbackPerformed", fmt.Sprintf("nginx -t failed, restored the previous %s", testErr.Conf))
+		}
+		r.event(cm, corev1.EventTypeWarning, kerr.Reason(err), err.Error())
+		return ctrl.Result{}, err
+	}
+
+	if !changed {
+		return ctrl.Result{}, nil
+	}
+
+	r.event(cm, corev1.EventTypeNormal, "Reloaded", fmt.Sprintf("nginx reloaded with the settings of configmap %s", r.ConfigMap))
+
+	// the server blocks of the ingresses use the settings as well
+	return ctrl.Result{}, r.requeueIngresses(ctx)
+}
+
+// loadHeaders reads the ConfigMaps of the headers referenced by proxy-set-headers and add-headers into g,
+// a missing ConfigMap sets no headers
+func (r *ConfigMapReconciler) loadHeaders(ctx context.Context, g *config.Global) error {
+	for _, ref := range []struct {
+		key     string
+		name    string
+		request bool
+		headers *map[string]string
+	}{
+		{"proxy-set-headers", g.ProxySetHeaders, true, &g.RequestHeaders},
+		{"add-headers", g.AddHeaders, false, &g.ResponseHeaders},
+	} {
+		*ref.headers = nil
+		if ref.name == "" {
+			continue
+		}
+
+		namespace, name, _ := strings.Cut(ref.name, "/")
+		cm := new(corev1.ConfigMap)
+		if err := r.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, cm); err != nil {
+			if !errors.IsNotFound(err) {
+				return err
+			}
+			klog.Warningf("configmap %s of %s not found, no headers are set", ref.name, ref.key)
+			continue
+		}
+
+		headers, err := config.ParseHeaders(ref.key, cm.Data, ref.request)
+		if err != nil {
+			return err
+		}
+		*ref.headers = headers
+	}
+
+	return nil
+}
+
+// apply renders nginx.conf with the new settings and reloads nginx, restoring the previous settings when nginx -t fails
+func (r *ConfigMapReconciler) apply(g *config.Global) (bool, error) {
+	configMux.Lock()
+	defer configMux.Unlock()
+
+	prev := config.GetGlobal()
+	if reflect.DeepEqual(prev, g) {
+		return false, nil
+	}
+
+	config.SetGlobal(g)
+
+	if err := NewConfHandler().UpdateDefaultConf(mainConfTemplate()); err != nil {
+		config.SetGlobal(prev)
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (r *ConfigMapReconciler) requeueIngresses(ctx context.Context) error {
+	custom := new(ingressv1.IngressList)
+	if err := r.List(ctx, custom); err != nil {
+		return err
+	}
+	for _, ing := range custom.Items {
+		applied.enqueue(client.ObjectKeyFromObject(&ing), false)
+	}
+
+	core := new(netv1.IngressList)
+	if err := r.List(ctx, core); err != nil {
+		return err
+	}
+	for _, ing := range core.Items {
+		applied.enqueue(client.ObjectKeyFromObject(&ing), true)
+	}
+
+	return nil
+}
+
+func (r *ConfigMapReconciler) event(cm *corev1.ConfigMap, eventType, reason, msg string) {
+	if cm != nil {
+		r.Recorder.Event(cm, eventType, reason, msg)
+	}
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ConfigMapReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("configmap").
+		For(&corev1.ConfigMap{}, builder.WithPredicates(predicate.NewPredicateFuncs(func(obj client.Object) bool {
+			// the ConfigMaps of the headers are applied through the global ConfigMap as well
+			key := client.ObjectKeyFromObject(obj)
+			g := config.GetGlobal()
+			return key == r.ConfigMap || key.String() == g.ProxySetHeaders || key.String() == g.AddHeaders
+		}))).
+		Complete(r)
+}
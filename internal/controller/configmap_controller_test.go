@@ -0,0 +1,87 @@
+package controller
+
+import (
+	"context"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/config"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"reflect"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"testing"
+)
+
+func TestLoadHeaders(t *testing.T) {
+	cms := []*corev1.ConfigMap{
+		{ObjectMeta: metav1.ObjectMeta{Name: "request", Namespace: "ingress-nginx"}, Data: map[string]string{"x-env": "prod"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "response", Namespace: "ingress-nginx"}, Data: map[string]string{"x-frame-options": "DENY"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "controller", Namespace: "ingress-nginx"}, Data: map[string]string{"X-Real-IP": "1.1.1.1"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "invalid", Namespace: "ingress-nginx"}, Data: map[string]string{"X-Env": `prod"`}},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(cms[0], cms[1], cms[2], cms[3]).Build()
+	r := &ConfigMapReconciler{Client: c}
+
+	tests := []struct {
+		name         string
+		request      string
+		response     string
+		wantRequest  map[string]string
+		wantResponse map[string]string
+		wantErr      bool
+	}{
+		{name: "no configmaps"},
+		{
+			name:         "request and response headers",
+			request:      "ingress-nginx/request",
+			response:     "ingress-nginx/response",
+			wantRequest:  map[string]string{"X-Env": "prod"},
+			wantResponse: map[string]string{"X-Frame-Options": "DENY"},
+		},
+		{
+			name:         "missing configmap sets no headers",
+			request:      "ingress-nginx/missing",
+			response:     "ingress-nginx/response",
+			wantResponse: map[string]string{"X-Frame-Options": "DENY"},
+		},
+		{
+			name:         "controller header on the responses",
+			response:     "ingress-nginx/controller",
+			wantResponse: map[string]string{"X-Real-Ip": "1.1.1.1"},
+		},
+		{
+			name:    "controller header on the requests",
+			request: "ingress-nginx/controller",
+			wantErr: true,
+		},
+		{
+			name:     "invalid value",
+			response: "ingress-nginx/invalid",
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := config.NewGlobal()
+			g.ProxySetHeaders = tt.request
+			g.AddHeaders = tt.response
+			// the headers of the previous settings are replaced
+			g.RequestHeaders = map[string]string{"X-Stale": "1"}
+			g.ResponseHeaders = map[string]string{"X-Stale": "1"}
+
+			err := r.loadHeaders(context.Background(), g)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadHeaders() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if !reflect.DeepEqual(g.RequestHeaders, tt.wantRequest) {
+				t.Errorf("loadHeaders() request headers = %v, want %v", g.RequestHeaders, tt.wantRequest)
+			}
+			if !reflect.DeepEqual(g.ResponseHeaders, tt.wantResponse) {
+				t.Errorf("loadHeaders() response headers = %v, want %v", g.ResponseHeaders, tt.wantResponse)
+			}
+		})
+	}
+}
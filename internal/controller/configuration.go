@@ -0,0 +1,438 @@
+package controller
+
+import (
+	"fmt"
+	ingressv1 "github.com/Lxb921006/ingress-nginx-kubebuilder/api/v1"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations/canary"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations/parser"
+	netv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"reflect"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// appliedIngress is what an ingress contributes to the configuration of nginx
+type appliedIngress struct {
+	key     types.NamespacedName
+	core    bool
+	created metav1.Time
+	servers []*ingressv1.Server
+	// rendered are the hosts whose merged server blocks the ingress renders
+	rendered []string
+	// canary is set when the paths of the ingress are the canary of the ingresses declaring them
+	canary *canary.Config
+	// annotations are the server level annotations of the ingress, only the ones of the owner of a host apply
+	annotations map[string]string
+}
+
+// serverAnnotations are rendered into the server block of a host rather than the locations of the paths
+var serverAnnotations = []string{
+	"auth-tls-pass-certificate-to-upstream", "auth-tls-secret", "auth-tls-verify-client", "auth-tls-verify-depth",
+	"disable-http", "force-ssl-redirect",
+	"hsts", "hsts-include-subdomains", "hsts-max-age", "hsts-preload",
+	"proxy-host", "proxy-path", "proxy-sslstapling", "proxy-target",
+	"redirect-code", "redirect-enable-regex", "redirect-host", "redirect-path", "redirect-preserve-uri", "redirect-scheme",
+	"server-snippet",
+	"ssl-ciphers", "ssl-protocols", "ssl-session-tickets", "ssl-session-timeout", "ssl-stapling", "ssl-stapling-verify",
+}
+
+// serverAnnotationsOf returns the server level annotations set on the ingress
+func serverAnnotationsOf(ing *ingressv1.Ingress) map[string]string {
+	anns := make(map[string]string)
+	for _, name := range serverAnnotations {
+		if val, ok := ing.Annotations[parser.GetAnnotationWithPrefix(name)]; ok {
+			anns[name] = val
+		}
+	}
+
+	return anns
+}
+
+// requiresClientCertificates reports whether the ingress verifies client certificates with settings the owner of its host does not share
+func (a *appliedIngress) requiresClientCertificates(owner *appliedIngress) bool {
+	if a.annotations["auth-tls-secret"] == "" {
+		return false
+	}
+
+	for _, name := range []string{"auth-tls-secret", "auth-tls-verify-client", "auth-tls-verify-depth"} {
+		if a.annotations[name] != owner.annotations[name] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ignoredAnnotations returns the server level annotations of the ingress the owner of its host does not share
+func (a *appliedIngress) ignoredAnnotations(owner *appliedIngress) []string {
+	var ignored []string
+	for _, name := range serverAnnotations {
+		val, ok := a.annotations[name]
+		if !ok {
+			continue
+		}
+		if ownerVal, ok := owner.annotations[name]; !ok || ownerVal != val {
+			ignored = append(ignored, name)
+		}
+	}
+
+	return ignored
+}
+
+// removedIngress is what a deleted ingress leaves behind
+type removedIngress struct {
+	// servers and defaultBackend report whether the server blocks and the default backend of the ingress were applied
+	servers        bool
+	defaultBackend bool
+	// owners render the hosts the paths of the ingress were merged into, they reference its upstreams
+	// and secret files, which stay until they render without them
+	owners []*appliedIngress
+	// released are the deleted ingresses the removed one was the last owner referencing the files of
+	released []types.NamespacedName
+}
+
+// hosts returns the hosts of the ingress in the order of its rules
+func (a *appliedIngress) hosts() []string {
+	var hosts []string
+	seen := sets.New[string]()
+	for _, s := range a.servers {
+		if !seen.Has(s.HostName) {
+			seen.Insert(s.HostName)
+			hosts = append(hosts, s.HostName)
+		}
+	}
+
+	return hosts
+}
+
+// renders reports whether the ingress renders the server block of the host
+func (a *appliedIngress) renders(host string) bool {
+	return sets.New(a.rendered...).Has(host)
+}
+
+// backend returns the backend of the ingress serving the host and path
+func (a *appliedIngress) backend(host, path string) *ingressv1.Backend {
+	for _, s := range a.servers {
+		if s.HostName != host {
+			continue
+		}
+		for _, b := range s.Paths {
+			if b.Path == path {
+				return b
+			}
+		}
+	}
+
+	return nil
+}
+
+// before orders the ingresses sharing a host, the oldest one owns it
+func (a *appliedIngress) before(b *appliedIngress) bool {
+	if !a.created.Equal(&b.created) {
+		return a.created.Before(&b.created)
+	}
+
+	return a.key.String() < b.key.String()
+}
+
+// appliedConfiguration is the model of every ingress applied to nginx. The paths of the ingresses sharing
+// a host are merged into the server block of the oldest one, which renders it into its configuration file,
+// and a path declared by several of them is served by the oldest. Canary ingresses never own a host, their
+// paths are routed by the owner of the host next to the paths they share with the other ingresses.
+// A younger ingress applied first, e.g. after a restart, renders the host until the owner is applied, which
+// waits for it to hand the host over, so nginx never sees a server block twice.
+type appliedConfiguration struct {
+	mux       sync.RWMutex
+	ingresses map[types.NamespacedName]*appliedIngress
+	// defaultBackend is the ingress whose default backend is rendered into nginx.conf, defaultServer is
+	// the data it was rendered from, nginx.conf is rendered from it again when the global settings change
+	defaultBackend types.NamespacedName
+	defaultServer  *defaultConf
+	// retiring are the deleted ingresses whose files are referenced by the owners of their hosts until they render again
+	retiring map[types.NamespacedName]sets.Set[types.NamespacedName]
+	// ingresses whose merged server blocks changed are sent back to their reconciler
+	customEvents chan event.GenericEvent
+	coreEvents   chan event.GenericEvent
+}
+
+var applied = &appliedConfiguration{
+	ingresses:    make(map[types.NamespacedName]*appliedIngress),
+	retiring:     make(map[types.NamespacedName]sets.Set[types.NamespacedName]),
+	customEvents: make(chan event.GenericEvent),
+	coreEvents:   make(chan event.GenericEvent),
+}
+
+// merge returns the server blocks the ingress renders, the hosts it owns with the paths of the younger
+// ingresses sharing them, the canaries of these paths, and its paths shadowed by an older ingress.
+func (c *appliedConfiguration) merge(self *appliedIngress) ([]*ingressv1.Server, []canaryRoute, []string) {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+
+	if self.canary != nil {
+		return nil, nil, c.canaryConflicts(self)
+	}
+
+	var servers []*ingressv1.Server
+	var routes []canaryRoute
+	var conflicts []string
+
+	for _, host := range self.hosts() {
+		claimants := []*appliedIngress{self}
+		var canaries []*appliedIngress
+		for key, a := range c.ingresses {
+			if key == self.key || !sets.New(a.hosts()...).Has(host) {
+				continue
+			}
+			if a.canary != nil {
+				canaries = append(canaries, a)
+				continue
+			}
+			claimants = append(claimants, a)
+		}
+		sort.Slice(claimants, func(i, j int) bool {
+			return claimants[i].before(claimants[j])
+		})
+		sort.Slice(canaries, func(i, j int) bool {
+			return canaries[i].before(canaries[j])
+		})
+
+		owner := claimants[0]
+		var merged *ingressv1.Server
+		paths := make(map[string]*appliedIngress)
+
+		for _, a := range claimants {
+			// the server block of the owner does not verify the client certificates the paths require
+			if a != owner && a.requiresClientCertificates(owner) {
+				if a == self {
+					conflicts = append(conflicts, fmt.Sprintf("paths of host %s are not served, they require client certificates "+
+						"the older ingress %s rendering its server block does not verify with the same auth-tls settings", host, owner.key))
+				}
+				continue
+			}
+
+			for _, s := range a.servers {
+				if s.HostName != host {
+					continue
+				}
+
+				if merged == nil {
+					server := *s
+					server.Paths = nil
+					merged = &server
+				}
+
+				for _, b := range s.Paths {
+					if winner, ok := paths[b.Path]; ok {
+						if a == self && winner != self {
+							conflicts = append(conflicts, fmt.Sprintf("path %s of host %s is served by the older ingress %s", b.Path, host, winner.key))
+						}
+						continue
+					}
+					paths[b.Path] = a
+					merged.Paths = append(merged.Paths, b)
+				}
+			}
+		}
+
+		if owner != self {
+			if ignored := self.ignoredAnnotations(owner); len(ignored) > 0 && !self.requiresClientCertificates(owner) {
+				conflicts = append(conflicts, fmt.Sprintf("annotations %s of host %s are ignored, its server block is rendered by the older ingress %s",
+					strings.Join(ignored, ", "), host, owner.key))
+			}
+			continue
+		}
+
+		handover := false
+		for _, a := range claimants[1:] {
+			handover = handover || a.renders(host)
+		}
+		if handover {
+			continue
+		}
+
+		// the oldest canary of a path takes it, the backends are copied as the applied ones are shared
+		for i, b := range merged.Paths {
+			for _, a := range canaries {
+				if cb := a.backend(host, b.Path); cb != nil {
+					route := newCanaryRoute(host, b, cb, a.canary)
+					primary := *b
+					primary.Canary = route.Variable()
+					merged.Paths[i] = &primary
+					routes = append(routes, route)
+					break
+				}
+			}
+		}
+
+		servers = append(servers, merged)
+	}
+
+	return servers, routes, conflicts
+}
+
+// canaryConflicts returns the paths of a canary ingress no other ingress declares, or taken by an older canary
+func (c *appliedConfiguration) canaryConflicts(self *appliedIngress) []string {
+	var conflicts []string
+	for _, s := range self.servers {
+		for _, b := range s.Paths {
+			var primary bool
+			var older *appliedIngress
+			for key, a := range c.ingresses {
+				if key == self.key || a.backend(s.HostName, b.Path) == nil {
+					continue
+				}
+				if a.canary == nil {
+					primary = true
+				} else if a.before(self) && (older == nil || a.before(older)) {
+					older = a
+				}
+			}
+
+			if !primary {
+				conflicts = append(conflicts, fmt.Sprintf("canary path %s of host %s is not declared by any other ingress", b.Path, s.HostName))
+			} else if older != nil {
+				conflicts = append(conflicts, fmt.Sprintf("canary path %s of host %s is taken by the older canary ingress %s", b.Path, s.HostName, older.key))
+			}
+		}
+	}
+
+	return conflicts
+}
+
+// set records the ingress as applied and requeues the ingresses whose merged server blocks it changes
+func (c *appliedConfiguration) set(a *appliedIngress) {
+	c.mux.Lock()
+	old := c.ingresses[a.key]
+	c.ingresses[a.key] = a
+	// an ingress created again with the name of a retiring one renders its files again
+	delete(c.retiring, a.key)
+	c.mux.Unlock()
+
+	if old != nil && old.created.Equal(&a.created) && reflect.DeepEqual(old.servers, a.servers) &&
+		reflect.DeepEqual(old.rendered, a.rendered) && reflect.DeepEqual(old.canary, a.canary) &&
+		reflect.DeepEqual(old.annotations, a.annotations) {
+		return
+	}
+
+	hosts := sets.New(a.hosts()...)
+	if old != nil {
+		hosts.Insert(old.hosts()...)
+	}
+
+	c.requeue(hosts, a.key)
+}
+
+// setDefaultBackend records the ingress whose default backend nginx.conf serves and the data it was rendered from
+func (c *appliedConfiguration) setDefaultBackend(key types.NamespacedName, server *defaultConf) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	c.defaultBackend = key
+	c.defaultServer = server
+}
+
+// defaultBackendServer returns the data of the applied default backend, nil when no ingress declares one
+func (c *appliedConfiguration) defaultBackendServer() *defaultConf {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+
+	return c.defaultServer
+}
+
+// remove forgets a deleted ingress, the younger ingresses sharing its hosts take them over
+func (c *appliedConfiguration) remove(key types.NamespacedName) removedIngress {
+	var removed removedIngress
+
+	c.mux.Lock()
+	old := c.ingresses[key]
+	delete(c.ingresses, key)
+	if c.defaultBackend == key {
+		removed.defaultBackend = true
+		c.defaultBackend = types.NamespacedName{}
+		c.defaultServer = nil
+	}
+	if old != nil {
+		removed.servers = true
+		owners := sets.New[types.NamespacedName]()
+		for _, a := range c.ingresses {
+			if sets.New(a.rendered...).HasAny(old.hosts()...) {
+				removed.owners = append(removed.owners, a)
+				owners.Insert(a.key)
+			}
+		}
+		if owners.Len() > 0 {
+			c.retiring[key] = owners
+		}
+	}
+	removed.released = c.releaseLocked(key)
+	c.mux.Unlock()
+
+	if old != nil {
+		c.requeue(sets.New(old.hosts()...), key)
+	}
+
+	return removed
+}
+
+// release returns the deleted ingresses whose files are no longer referenced once the owner rendered again
+func (c *appliedConfiguration) release(owner types.NamespacedName) []types.NamespacedName {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	return c.releaseLocked(owner)
+}
+
+func (c *appliedConfiguration) releaseLocked(owner types.NamespacedName) []types.NamespacedName {
+	var released []types.NamespacedName
+	for key, owners := range c.retiring {
+		if !owners.Has(owner) {
+			continue
+		}
+		owners.Delete(owner)
+		if owners.Len() == 0 {
+			delete(c.retiring, key)
+			released = append(released, key)
+		}
+	}
+
+	sort.Slice(released, func(i, j int) bool {
+		return released[i].String() < released[j].String()
+	})
+
+	return released
+}
+
+func (c *appliedConfiguration) requeue(hosts sets.Set[string], exclude types.NamespacedName) {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+
+	for key, a := range c.ingresses {
+		if key == exclude || !hosts.HasAny(a.hosts()...) {
+			continue
+		}
+
+		c.enqueue(key, a.core)
+	}
+}
+
+// enqueue sends an ingress back to its reconciler
+func (c *appliedConfiguration) enqueue(key types.NamespacedName, core bool) {
+	meta := metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace}
+	var obj client.Object = &ingressv1.Ingress{ObjectMeta: meta}
+	events := c.customEvents
+	if core {
+		obj = &netv1.Ingress{ObjectMeta: meta}
+		events = c.coreEvents
+	}
+
+	// the reconciler sending it may be holding the queue, never block on it
+	go func() {
+		events <- event.GenericEvent{Object: obj}
+	}()
+}
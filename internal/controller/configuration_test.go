@@ -0,0 +1,318 @@
+package controller
+
+import (
+	ingressv1 "github.com/Lxb921006/ingress-nginx-kubebuilder/api/v1"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations/canary"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"reflect"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"testing"
+	"time"
+)
+
+// testIngress returns an applied ingress created age minutes ago, serving the paths of host
+func testIngress(name string, age int, host string, paths ...string) *appliedIngress {
+	server := &ingressv1.Server{Name: name, NameSpace: "default", HostName: host}
+	for _, p := range paths {
+		server.Paths = append(server.Paths, &ingressv1.Backend{Name: name, IngName: name, NameSpace: "default", Path: p})
+	}
+
+	return &appliedIngress{
+		key:     types.NamespacedName{Name: name, Namespace: "default"},
+		created: metav1.NewTime(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).Add(-time.Duration(age) * time.Minute)),
+		servers: []*ingressv1.Server{server},
+	}
+}
+
+func withRendered(a *appliedIngress, hosts ...string) *appliedIngress {
+	a.rendered = hosts
+	return a
+}
+
+func withAnnotations(a *appliedIngress, anns map[string]string) *appliedIngress {
+	a.annotations = anns
+	return a
+}
+
+func withCanary(a *appliedIngress, cfg canary.Config) *appliedIngress {
+	a.canary = &cfg
+	return a
+}
+
+func newTestConfiguration(ingresses ...*appliedIngress) *appliedConfiguration {
+	c := &appliedConfiguration{
+		ingresses:    make(map[types.NamespacedName]*appliedIngress),
+		retiring:     make(map[types.NamespacedName]sets.Set[types.NamespacedName]),
+		customEvents: make(chan event.GenericEvent, 10),
+		coreEvents:   make(chan event.GenericEvent, 10),
+	}
+	for _, a := range ingresses {
+		c.ingresses[a.key] = a
+	}
+
+	return c
+}
+
+// paths returns the host and the path and ingress of every backend of servers
+func paths(servers []*ingressv1.Server) map[string][]string {
+	got := make(map[string][]string)
+	for _, s := range servers {
+		for _, b := range s.Paths {
+			got[s.HostName] = append(got[s.HostName], b.Path+" "+b.IngName)
+		}
+	}
+
+	return got
+}
+
+func TestAppliedConfigurationMerge(t *testing.T) {
+	tests := []struct {
+		name          string
+		applied       []*appliedIngress
+		self          *appliedIngress
+		wantPaths     map[string][]string
+		wantConflicts int
+		wantCanaries  int
+	}{
+		{
+			name:      "single ingress",
+			self:      testIngress("a", 10, "a.com", "/", "/api"),
+			wantPaths: map[string][]string{"a.com": {"/ a", "/api a"}},
+		},
+		{
+			name:      "younger paths are merged into the owner",
+			applied:   []*appliedIngress{testIngress("b", 5, "a.com", "/b")},
+			self:      testIngress("a", 10, "a.com", "/"),
+			wantPaths: map[string][]string{"a.com": {"/ a", "/b b"}},
+		},
+		{
+			name:      "younger ingress renders nothing",
+			applied:   []*appliedIngress{withRendered(testIngress("a", 10, "a.com", "/"), "a.com")},
+			self:      testIngress("b", 5, "a.com", "/b"),
+			wantPaths: map[string][]string{},
+		},
+		{
+			name:          "path of the younger ingress is shadowed",
+			applied:       []*appliedIngress{withRendered(testIngress("a", 10, "a.com", "/"), "a.com")},
+			self:          testIngress("b", 5, "a.com", "/"),
+			wantPaths:     map[string][]string{},
+			wantConflicts: 1,
+		},
+		{
+			name:      "older ingress serves the shared path",
+			applied:   []*appliedIngress{testIngress("b", 5, "a.com", "/")},
+			self:      testIngress("a", 10, "a.com", "/"),
+			wantPaths: map[string][]string{"a.com": {"/ a"}},
+		},
+		{
+			name:      "exact and prefix paths are different locations",
+			applied:   []*appliedIngress{testIngress("b", 5, "a.com", "= /api")},
+			self:      testIngress("a", 10, "a.com", "/api"),
+			wantPaths: map[string][]string{"a.com": {"/api a", "= /api b"}},
+		},
+		{
+			name:      "same age is ordered by key",
+			applied:   []*appliedIngress{testIngress("b", 10, "a.com", "/b")},
+			self:      testIngress("a", 10, "a.com", "/"),
+			wantPaths: map[string][]string{"a.com": {"/ a", "/b b"}},
+		},
+		{
+			name:      "owner waits for the younger ingress rendering the host to hand it over",
+			applied:   []*appliedIngress{withRendered(testIngress("b", 5, "a.com", "/b"), "a.com")},
+			self:      testIngress("a", 10, "a.com", "/"),
+			wantPaths: map[string][]string{},
+		},
+		{
+			name:      "other hosts are not handed over",
+			applied:   []*appliedIngress{withRendered(testIngress("b", 5, "b.com", "/b"), "b.com")},
+			self:      testIngress("a", 10, "a.com", "/"),
+			wantPaths: map[string][]string{"a.com": {"/ a"}},
+		},
+		{
+			name:          "server annotations of a younger ingress are ignored",
+			applied:       []*appliedIngress{withRendered(testIngress("a", 10, "a.com", "/"), "a.com")},
+			self:          withAnnotations(testIngress("b", 5, "a.com", "/b"), map[string]string{"force-ssl-redirect": "true"}),
+			wantPaths:     map[string][]string{},
+			wantConflicts: 1,
+		},
+		{
+			name: "server annotations shared with the owner",
+			applied: []*appliedIngress{withAnnotations(withRendered(testIngress("a", 10, "a.com", "/"), "a.com"),
+				map[string]string{"force-ssl-redirect": "true"})},
+			self:      withAnnotations(testIngress("b", 5, "a.com", "/b"), map[string]string{"force-ssl-redirect": "true"}),
+			wantPaths: map[string][]string{},
+		},
+		{
+			name:    "paths requiring client certificates are not merged",
+			applied: []*appliedIngress{testIngress("b", 5, "a.com", "/b")},
+			self: withAnnotations(testIngress("a", 10, "a.com", "/"),
+				map[string]string{"auth-tls-secret": "ca"}),
+			wantPaths: map[string][]string{"a.com": {"/ a", "/b b"}},
+		},
+		{
+			name:          "owner without the client certificates of a younger ingress",
+			applied:       []*appliedIngress{withRendered(testIngress("a", 10, "a.com", "/"), "a.com")},
+			self:          withAnnotations(testIngress("b", 5, "a.com", "/b"), map[string]string{"auth-tls-secret": "ca"}),
+			wantPaths:     map[string][]string{},
+			wantConflicts: 1,
+		},
+		{
+			name: "owner rendering a younger ingress requiring client certificates",
+			applied: []*appliedIngress{
+				withAnnotations(testIngress("b", 5, "a.com", "/b"), map[string]string{"auth-tls-secret": "ca"}),
+				testIngress("c", 1, "a.com", "/c"),
+			},
+			self:      testIngress("a", 10, "a.com", "/"),
+			wantPaths: map[string][]string{"a.com": {"/ a", "/c c"}},
+		},
+		{
+			name: "owner verifying the same client certificates",
+			applied: []*appliedIngress{
+				withAnnotations(testIngress("b", 5, "a.com", "/b"), map[string]string{"auth-tls-secret": "ca"}),
+			},
+			self:      withAnnotations(testIngress("a", 10, "a.com", "/"), map[string]string{"auth-tls-secret": "ca"}),
+			wantPaths: map[string][]string{"a.com": {"/ a", "/b b"}},
+		},
+		{
+			name:         "canary of a path",
+			applied:      []*appliedIngress{withCanary(testIngress("c", 1, "a.com", "/"), canary.Config{Enabled: true, Weight: 20})},
+			self:         testIngress("a", 10, "a.com", "/"),
+			wantPaths:    map[string][]string{"a.com": {"/ a"}},
+			wantCanaries: 1,
+		},
+		{
+			name:          "canary without a primary path",
+			applied:       []*appliedIngress{withRendered(testIngress("a", 10, "a.com", "/"), "a.com")},
+			self:          withCanary(testIngress("c", 1, "a.com", "/other"), canary.Config{Enabled: true, Weight: 20}),
+			wantPaths:     map[string][]string{},
+			wantConflicts: 1,
+		},
+		{
+			name: "canary taken by an older canary",
+			applied: []*appliedIngress{
+				withRendered(testIngress("a", 10, "a.com", "/"), "a.com"),
+				withCanary(testIngress("c", 5, "a.com", "/"), canary.Config{Enabled: true, Weight: 20}),
+			},
+			self:          withCanary(testIngress("d", 1, "a.com", "/"), canary.Config{Enabled: true, Weight: 50}),
+			wantPaths:     map[string][]string{},
+			wantConflicts: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestConfiguration(tt.applied...)
+
+			servers, canaries, conflicts := c.merge(tt.self)
+			if got := paths(servers); !reflect.DeepEqual(got, tt.wantPaths) {
+				t.Errorf("merge() paths = %v, want %v", got, tt.wantPaths)
+			}
+			if len(conflicts) != tt.wantConflicts {
+				t.Errorf("merge() conflicts = %v, want %d", conflicts, tt.wantConflicts)
+			}
+			if len(canaries) != tt.wantCanaries {
+				t.Errorf("merge() canaries = %v, want %d", canaries, tt.wantCanaries)
+			}
+			for _, route := range canaries {
+				if servers[0].Paths[0].Canary != route.Variable() {
+					t.Errorf("merge() path proxies to %q, want %q", servers[0].Paths[0].Canary, route.Variable())
+				}
+			}
+		})
+	}
+}
+
+func TestAppliedConfigurationRemove(t *testing.T) {
+	owner := withRendered(testIngress("a", 10, "a.com", "/"), "a.com")
+	other := withRendered(testIngress("o", 10, "o.com", "/"), "o.com")
+
+	tests := []struct {
+		name       string
+		remove     *appliedIngress
+		defBackend bool
+		want       removedIngress
+		wantOwners []string
+	}{
+		{
+			name:       "contributor",
+			remove:     testIngress("b", 5, "a.com", "/b"),
+			want:       removedIngress{servers: true},
+			wantOwners: []string{"default/a"},
+		},
+		{
+			name:   "owner",
+			remove: withRendered(testIngress("z", 20, "z.com", "/"), "z.com"),
+			want:   removedIngress{servers: true},
+		},
+		{
+			name:       "default backend",
+			defBackend: true,
+			want:       removedIngress{defaultBackend: true},
+		},
+		{
+			name: "never applied",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestConfiguration(owner, other)
+			key := types.NamespacedName{Name: "x", Namespace: "default"}
+			if tt.remove != nil {
+				key = tt.remove.key
+				c.ingresses[key] = tt.remove
+			}
+			if tt.defBackend {
+				c.defaultBackend = key
+			}
+
+			got := c.remove(key)
+			if got.servers != tt.want.servers || got.defaultBackend != tt.want.defaultBackend {
+				t.Errorf("remove() = %+v, want %+v", got, tt.want)
+			}
+			var owners []string
+			for _, a := range got.owners {
+				owners = append(owners, a.key.String())
+			}
+			if !reflect.DeepEqual(owners, tt.wantOwners) {
+				t.Errorf("remove() owners = %v, want %v", owners, tt.wantOwners)
+			}
+			if _, ok := c.ingresses[key]; ok {
+				t.Errorf("remove() kept %s", key)
+			}
+			if _, ok := c.retiring[key]; ok != (len(tt.wantOwners) > 0) {
+				t.Errorf("remove() retiring = %v, want the files kept for %v", ok, tt.wantOwners)
+			}
+		})
+	}
+}
+
+func TestAppliedConfigurationRelease(t *testing.T) {
+	owner := withRendered(testIngress("a", 10, "a.com", "/"), "a.com")
+	other := withRendered(testIngress("o", 10, "o.com", "/"), "o.com")
+	c := newTestConfiguration(owner, other, testIngress("b", 5, "a.com", "/b"), testIngress("c", 5, "o.com", "/c"))
+	key := func(name string) types.NamespacedName { return types.NamespacedName{Name: name, Namespace: "default"} }
+
+	c.remove(key("b"))
+	if got := c.release(key("o")); len(got) != 0 {
+		t.Errorf("release() = %v by an ingress not rendering the host", got)
+	}
+	if got, want := c.release(key("a")), []types.NamespacedName{key("b")}; !reflect.DeepEqual(got, want) {
+		t.Errorf("release() = %v, want %v", got, want)
+	}
+
+	// the files of c are released by the deletion of its only owner
+	c.remove(key("c"))
+	if got, want := c.remove(key("o")).released, []types.NamespacedName{key("c")}; !reflect.DeepEqual(got, want) {
+		t.Errorf("remove() released = %v, want %v", got, want)
+	}
+
+	// an ingress created again renders its own files
+	c.remove(key("a"))
+	c.set(testIngress("a", 30, "a.com", "/"))
+	if len(c.retiring) != 0 {
+		t.Errorf("set() kept retiring %v", c.retiring)
+	}
+}
@@ -0,0 +1,163 @@
+package controller
+
+import (
+	"context"
+	ingressv1 "github.com/Lxb921006/ingress-nginx-kubebuilder/api/v1"
+	v1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	netv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// CoreIngressReconciler reconciles networking.k8s.io/v1 Ingress objects. They are converted into the
+// ingress.nginx.kubebuilder.io Ingress, whose spec mirrors them, and go through the same annotations
+// and templates, so existing manifests can be served without rewriting them.
+type CoreIngressReconciler struct {
+	IngressReconciler
+}
+
+//+kubebuilder:rbac:groups=networking.k8s.io,resources=ingresses,verbs=get;list;watch
+//+kubebuilder:rbac:groups=networking.k8s.io,resources=ingresses/status,verbs=get;update;patch
+
+func (r *CoreIngressReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	var ing = new(netv1.Ingress)
+
+	if err := r.Get(ctx, req.NamespacedName, ing); err != nil {
+		klog.Infof("ingress resource %s not found in namesapce %s, maybe has been deleted", req.Name, req.Namespace)
+		if !r.hasCustomIngress(ctx, req.NamespacedName) {
+			r.clearConf(ctx, req.NamespacedName)
+		}
+		return ctrl.Result{}, nil
+	}
+
+	// an ingress moved to another class only has its configuration removed, its status belongs to the other controller
+	if !r.selectsController(ing) {
+		if !r.hasCustomIngress(ctx, req.NamespacedName) {
+			r.clearConf(ctx, req.NamespacedName)
+		}
+		return ctrl.Result{}, nil
+	}
+
+	// both kinds render into conf.d/<name>-<namespace>.conf, the custom resource takes precedence
+	if r.hasCustomIngress(ctx, req.NamespacedName) {
+		r.Recorder.Eventf(ing, v1.EventTypeWarning, "NameConflict",
+			"ignored, the ingress.nginx.kubebuilder.io Ingress %s in namespace %s has the same name", ing.Name, ing.Namespace)
+		return ctrl.Result{}, nil
+	}
+
+	r.object = ing
+
+	return r.reconcile(ctx, ingressFromCore(ing))
+}
+
+// selectsController reports whether the ingress selects the controller, by the class annotation or its IngressClass
+func (r *CoreIngressReconciler) selectsController(obj client.Object) bool {
+	ing, ok := obj.(*netv1.Ingress)
+	if !ok {
+		return false
+	}
+
+	if ing.Annotations[nginxAnnotationKey] == nginxAnnotationVal {
+		return true
+	}
+
+	if ing.Spec.IngressClassName == nil {
+		return false
+	}
+
+	ic := new(netv1.IngressClass)
+	if err := r.Get(context.Background(), types.NamespacedName{Name: *ing.Spec.IngressClassName}, ic); err != nil {
+		return false
+	}
+
+	return ic.Spec.Controller == controller
+}
+
+// classPredicate passes the events of the ingresses selecting the controller, and the updates of the ones
+// moved to another class so their configuration is cleared
+func (r *CoreIngressReconciler) classPredicate() predicate.Predicate {
+	return predicate.Funcs{
+		CreateFunc: func(e event.CreateEvent) bool { return r.selectsController(e.Object) },
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			return r.selectsController(e.ObjectOld) || r.selectsController(e.ObjectNew)
+		},
+		DeleteFunc:  func(e event.DeleteEvent) bool { return r.selectsController(e.Object) },
+		GenericFunc: func(e event.GenericEvent) bool { return r.selectsController(e.Object) },
+	}
+}
+
+// customIngressToIngress enqueues the networking.k8s.io/v1 Ingress named like a custom Ingress,
+// so it is served again once the custom Ingress it conflicts with is deleted
+func (r *CoreIngressReconciler) customIngressToIngress(ctx context.Context, obj client.Object) []reconcile.Request {
+	ing := new(netv1.Ingress)
+	if err := r.Get(ctx, client.ObjectKeyFromObject(obj), ing); err != nil || !r.selectsController(ing) {
+		return nil
+	}
+
+	return []reconcile.Request{{NamespacedName: client.ObjectKeyFromObject(obj)}}
+}
+
+func (r *CoreIngressReconciler) hasCustomIngress(ctx context.Context, key client.ObjectKey) bool {
+	return r.Get(ctx, key, new(ingressv1.Ingress)) == nil
+}
+
+// ingressFromCore converts a networking.k8s.io/v1 Ingress into the custom Ingress the pipeline works on
+func ingressFromCore(ing *netv1.Ingress) *ingressv1.Ingress {
+	ic := &ingressv1.Ingress{
+		ObjectMeta: *ing.ObjectMeta.DeepCopy(),
+		Spec: ingressv1.IngressSpec{
+			DefaultBackend: ing.Spec.DefaultBackend.DeepCopy(),
+		},
+	}
+
+	if ing.Spec.IngressClassName != nil {
+		ic.Spec.IngressClassName = *ing.Spec.IngressClassName
+	}
+
+	for _, tls := range ing.Spec.TLS {
+		ic.Spec.TLS = append(ic.Spec.TLS, *tls.DeepCopy())
+	}
+
+	for _, rule := range ing.Spec.Rules {
+		ic.Spec.Rules = append(ic.Spec.Rules, *rule.DeepCopy())
+	}
+
+	return ic
+}
+
+// SetupWithManager sets up the controller with the Manager. nginx is started by the IngressReconciler.
+func (r *CoreIngressReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if err := setupIndexes(context.Background(), mgr.GetFieldIndexer(), &netv1.Ingress{}, func(obj client.Object) *ingressv1.Ingress {
+		return ingressFromCore(obj.(*netv1.Ingress))
+	}); err != nil {
+		return err
+	}
+
+	r.coreIngress = true
+	r.dynamicClient = r.createDynamicClientSet()
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("core-ingress").
+		// the ingresses of the other controllers are neither rendered nor cleared
+		For(&netv1.Ingress{}, builder.WithPredicates(r.classPredicate(), predicate.Or(
+			predicate.GenerationChangedPredicate{},
+			predicate.AnnotationChangedPredicate{},
+			predicate.LabelChangedPredicate{},
+		))).
+		Watches(&discoveryv1.EndpointSlice{}, handler.EnqueueRequestsFromMapFunc(r.endpointSliceToIngresses)).
+		Watches(&v1.Service{}, handler.EnqueueRequestsFromMapFunc(r.serviceToIngresses)).
+		Watches(&v1.Secret{}, handler.EnqueueRequestsFromMapFunc(r.secretToIngresses)).
+		Watches(&netv1.IngressClass{}, handler.EnqueueRequestsFromMapFunc(r.ingressClassToIngresses)).
+		Watches(&ingressv1.Ingress{}, handler.EnqueueRequestsFromMapFunc(r.customIngressToIngress),
+			builder.WithPredicates(predicate.Funcs{UpdateFunc: func(event.UpdateEvent) bool { return false }})).
+		WatchesRawSource(source.Channel(applied.coreEvents, &handler.EnqueueRequestForObject{})).
+		Complete(r)
+}
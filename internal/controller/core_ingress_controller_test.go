@@ -0,0 +1,80 @@
+package controller
+
+import (
+	netv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"testing"
+)
+
+func TestSelectsController(t *testing.T) {
+	classes := []*netv1.IngressClass{
+		{ObjectMeta: metav1.ObjectMeta{Name: "kubebuilder"}, Spec: netv1.IngressClassSpec{Controller: controller}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "nginx"}, Spec: netv1.IngressClassSpec{Controller: "k8s.io/ingress-nginx"}},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(classes[0], classes[1]).Build()
+	r := &CoreIngressReconciler{IngressReconciler: IngressReconciler{Client: c}}
+
+	className := func(name string) *string { return &name }
+
+	tests := []struct {
+		name      string
+		anns      map[string]string
+		className *string
+		want      bool
+	}{
+		{name: "class annotation", anns: map[string]string{nginxAnnotationKey: nginxAnnotationVal}, want: true},
+		{name: "class of the controller", className: className("kubebuilder"), want: true},
+		{name: "class of another controller", className: className("nginx")},
+		{name: "annotation of another controller", anns: map[string]string{nginxAnnotationKey: "nginx"}},
+		{name: "missing class", className: className("missing")},
+		{name: "no class"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ing := &netv1.Ingress{
+				ObjectMeta: metav1.ObjectMeta{Name: "demo", Namespace: "default", Annotations: tt.anns},
+				Spec:       netv1.IngressSpec{IngressClassName: tt.className},
+			}
+			if got := r.selectsController(ing); got != tt.want {
+				t.Errorf("selectsController() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClassPredicate(t *testing.T) {
+	class := &netv1.IngressClass{ObjectMeta: metav1.ObjectMeta{Name: "kubebuilder"}, Spec: netv1.IngressClassSpec{Controller: controller}}
+	c := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(class).Build()
+	r := &CoreIngressReconciler{IngressReconciler: IngressReconciler{Client: c}}
+
+	ingress := func(class string) *netv1.Ingress {
+		return &netv1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{Name: "demo", Namespace: "default"},
+			Spec:       netv1.IngressSpec{IngressClassName: &class},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		old, new string
+		want     bool
+	}{
+		{name: "served", old: "kubebuilder", new: "kubebuilder", want: true},
+		{name: "moved to another class", old: "kubebuilder", new: "nginx", want: true},
+		{name: "moved to the controller", old: "nginx", new: "kubebuilder", want: true},
+		{name: "another class", old: "nginx", new: "nginx"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := event.UpdateEvent{ObjectOld: ingress(tt.old), ObjectNew: ingress(tt.new)}
+			if got := r.classPredicate().Update(e); got != tt.want {
+				t.Errorf("classPredicate().Update() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -3,36 +3,82 @@ package controller
 import (
 	"fmt"
 	ingressv1 "github.com/Lxb921006/ingress-nginx-kubebuilder/api/v1"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/config"
 	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/nginx"
 	"github.com/Lxb921006/ingress-nginx-kubebuilder/pkg/utils/template_nginx"
+	"k8s.io/klog/v2"
+	"os"
+	"strings"
 )
 
 type ConfHandler struct {
 }
 
+// defaultConf is the data of nginx.tmpl and defaultBackend.tmpl rendered without an ingress, the server of the
+// applied default backend or, when no ingress declares one, the zero Annotations rendering the default server
+type defaultConf struct {
+	Server      *ingressv1.Server
+	Annotations *annotations.Ingress
+	Global      *config.Global
+}
+
 func NewConfHandler() ConfHandler {
 	return ConfHandler{}
 }
 
 func (c ConfHandler) UpdateDefaultConf(parser *template_nginx.RenderTemplate) error {
-	var servers = new(ingressv1.Server)
-	var cfg = struct {
-		Server *ingressv1.Server
-	}{
-		Server: servers,
+	if err := c.RenderDefaultConf(parser); err != nil {
+		return err
+	}
+
+	if _, err := nginx.Reload(parser.GenerateName); err != nil {
+		klog.ErrorS(err, fmt.Sprintf("fail to reload nginx with %s.conf", parser.GenerateName))
+		return err
 	}
 
-	fmt.Println("UpdateDefaultConf >>> ", cfg)
+	return nil
+}
+
+// RenderDefaultConf renders <GenerateName>-test.conf with the global settings and the applied default backend
+func (c ConfHandler) RenderDefaultConf(parser *template_nginx.RenderTemplate) error {
+	cfg := defaultConf{
+		Server:      new(ingressv1.Server),
+		Annotations: new(annotations.Ingress),
+		Global:      config.GetGlobal(),
+	}
+	if server := applied.defaultBackendServer(); server != nil {
+		cfg.Server = server.Server
+		cfg.Annotations = server.Annotations
+	}
+
+	parser.MainData = cfg
 
 	if err := parser.Render(cfg); err != nil {
-		fmt.Println("Render >>> ", err)
+		klog.ErrorS(err, fmt.Sprintf("fail to render %s.conf", parser.GenerateName))
 		return err
 	}
 
-	if err := nginx.Reload(parser.GenerateName); err != nil {
-		fmt.Println("UpdateDefaultConf >>> ", err)
+	return nil
+}
+
+// RenderMainConf writes nginx.conf from the global settings before nginx starts, so the status server,
+// the lua balancer and the stream block exist before any ingress is reconciled
+func (c ConfHandler) RenderMainConf() error {
+	pr := mainConfTemplate()
+	if err := c.RenderDefaultConf(pr); err != nil {
 		return err
 	}
 
-	return nil
+	return os.Rename(pr.GenerateName+"-test.conf", config.MainConf)
+}
+
+// mainConfTemplate returns the templates of nginx.conf with the default server
+func mainConfTemplate() *template_nginx.RenderTemplate {
+	conf := strings.Split(config.MainConf, ".")
+	return &template_nginx.RenderTemplate{
+		GenerateName:       conf[0],
+		RenderTemplateName: config.DefaultTmpl,
+		MainTemplateName:   config.NginxTmpl,
+	}
 }
@@ -0,0 +1,93 @@
+package controller
+
+import (
+	ingressv1 "github.com/Lxb921006/ingress-nginx-kubebuilder/api/v1"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/config"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/pkg/utils/template_nginx"
+	"k8s.io/apimachinery/pkg/types"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const templateDir = "../../rootfs/etc/nginx/template"
+
+func TestRenderDefaultConf(t *testing.T) {
+	prev := config.GetGlobal()
+	defer config.SetGlobal(prev)
+
+	tests := []struct {
+		name           string
+		global         func(g *config.Global)
+		defaultBackend *defaultConf
+		want           []string
+	}{
+		{
+			name: "defaults",
+			want: []string{"server_name  _;", "ssl_protocols TLSv1.2 TLSv1.3;", "listen 127.0.0.1:10246;"},
+		},
+		{
+			name: "global settings",
+			global: func(g *config.Global) {
+				g.SSLProtocols = "TLSv1.3"
+				g.UseProxyProtocol = true
+			},
+			want: []string{"ssl_protocols TLSv1.3;", "listen       443 ssl proxy_protocol;"},
+		},
+		{
+			name: "applied default backend",
+			global: func(g *config.Global) {
+				g.UseGzip = true
+			},
+			defaultBackend: &defaultConf{
+				Server: &ingressv1.Server{Name: "demo", NameSpace: "default", HostName: "default", Paths: []*ingressv1.Backend{
+					{Name: "web", NameSpace: "default", Port: 8080, Path: "/", Annotations: new(annotations.Ingress)},
+				}},
+				Annotations: new(annotations.Ingress),
+			},
+			want: []string{"gzip on;", "_pass http://web.default.svc:8080;"},
+		},
+		{
+			name: "stream",
+			global: func(g *config.Global) {
+				g.Stream = true
+			},
+			want: []string{"stream {", "include /etc/nginx/stream.d/*.conf;"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := config.NewGlobal()
+			g.StatusPort = 10246
+			if tt.global != nil {
+				tt.global(g)
+			}
+			config.SetGlobal(g)
+			applied.setDefaultBackend(types.NamespacedName{Name: "demo", Namespace: "default"}, tt.defaultBackend)
+			defer applied.setDefaultBackend(types.NamespacedName{}, nil)
+
+			name := filepath.Join(t.TempDir(), "nginx")
+			pr := &template_nginx.RenderTemplate{
+				GenerateName:       name,
+				RenderTemplateName: filepath.Join(templateDir, filepath.Base(config.DefaultTmpl)),
+				MainTemplateName:   filepath.Join(templateDir, filepath.Base(config.NginxTmpl)),
+			}
+			if err := NewConfHandler().RenderDefaultConf(pr); err != nil {
+				t.Fatalf("RenderDefaultConf() error = %v", err)
+			}
+
+			b, err := os.ReadFile(name + "-test.conf")
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(string(b), want) {
+					t.Errorf("rendered conf does not contain %q", want)
+				}
+			}
+		})
+	}
+}
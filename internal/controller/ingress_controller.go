@@ -18,15 +18,18 @@ package controller
 
 import (
 	"context"
+	errs "errors"
 	"fmt"
 	ingressv1 "github.com/Lxb921006/ingress-nginx-kubebuilder/api/v1"
 	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations"
 	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/config"
 	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/controller/store"
+	kerr "github.com/Lxb921006/ingress-nginx-kubebuilder/internal/errors"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/metrics"
 	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/nginx"
 	"github.com/Lxb921006/ingress-nginx-kubebuilder/pkg/resources"
-	"github.com/Lxb921006/ingress-nginx-kubebuilder/pkg/utils/template_nginx"
 	v1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
 	netv1 "k8s.io/api/networking/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -34,22 +37,35 @@ import (
 	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/klog/v2"
-	"os"
 	"path/filepath"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
-	"strings"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 	"time"
 )
 
 // IngressReconciler reconciles a Ingress object
 type IngressReconciler struct {
 	client.Client
-	Scheme        *runtime.Scheme
-	dynamicClient *dynamic.DynamicClient
-	ctx           context.Context
-	ingress       *ingressv1.Ingress
+	Scheme               *runtime.Scheme
+	Recorder             record.EventRecorder
+	PublishStatusAddress string
+	dynamicClient        *dynamic.DynamicClient
+	ctx                  context.Context
+	ingress              *ingressv1.Ingress
+	// object is the reconciled object events and status are recorded on, the networking.k8s.io/v1
+	// Ingress when ingress was converted from it
+	object      client.Object
+	coreIngress bool
+	conflicts   []string
+	// served is false when the ingress does not select this controller, its status then
+	// stops advertising the addresses of the controller
+	served bool
 }
 
 //+kubebuilder:rbac:groups=ingress.nginx.kubebuilder.io,resources=ingresses,verbs=get;list;watch;create;update;patch;delete
@@ -59,6 +75,9 @@ type IngressReconciler struct {
 //+kubebuilder:rbac:groups=cert-manager.io,resources=issuers,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=core,resources=services,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
+//+kubebuilder:rbac:groups=discovery.k8s.io,resources=endpointslices,verbs=get;list;watch
+//+kubebuilder:rbac:groups=networking.k8s.io,resources=ingressclasses,verbs=get;list;watch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -77,7 +96,7 @@ func (r *IngressReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 
 	if err := r.Get(ctx, req.NamespacedName, ic); err != nil {
 		klog.Infof("ingress resource %s not found in namesapce %s, maybe has been deleted", req.NamespacedName.Name, req.NamespacedName.Namespace)
-		r.clearConf(req.NamespacedName)
+		r.clearConf(ctx, req.NamespacedName)
 		return ctrl.Result{}, nil
 	}
 
@@ -87,17 +106,33 @@ func (r *IngressReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 	//	return ctrl.Result{}, nil
 	//}
 
+	r.object = ic
+
+	return r.reconcile(ctx, ic)
+}
+
+// reconcile renders, tests and reloads the nginx configuration of an ingress
+func (r *IngressReconciler) reconcile(ctx context.Context, ic *ingressv1.Ingress) (ctrl.Result, error) {
 	r.ctx = ctx
 	r.ingress = ic
+	r.conflicts = nil
+	r.served = false
 
-	if info := r.checkController(); info != nil {
+	if err := r.checkController(); err != nil {
+		// an ingress moved to another class is no longer served
+		if kerr.IsNotSatisfiableError(err) {
+			r.clearConf(ctx, client.ObjectKeyFromObject(ic))
+		}
+		r.report(err)
 		return ctrl.Result{RequeueAfter: time.Second * time.Duration(30)}, nil
 	}
+	r.served = true
 
 	var key client.ObjectKey
 	if ic.Spec.DefaultBackend != nil {
 		key = types.NamespacedName{Name: ic.Spec.DefaultBackend.Service.Name, Namespace: ic.Namespace}
 		if err := r.checkService(key); err != nil {
+			r.report(err)
 			return ctrl.Result{RequeueAfter: time.Second * time.Duration(30)}, nil
 		}
 	}
@@ -115,6 +150,7 @@ func (r *IngressReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 	}
 
 	if len(errList) > 0 {
+		r.report(errs.Join(errList...))
 		return ctrl.Result{RequeueAfter: time.Second * time.Duration(30)}, nil
 	}
 
@@ -123,12 +159,14 @@ func (r *IngressReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 	rs.IngressInfos = store.NewIngressInfo(rs)
 
 	if err := resources.ReconcileResource(rs); err != nil {
+		r.report(err)
 		return ctrl.Result{RequeueAfter: time.Second * time.Duration(15)}, nil
 	}
 
 	parsed, err := annotations.NewAnnotationExtractor(rs.IngressInfos).Extract(ic)
 	if err != nil {
-		klog.ErrorS(err, fmt.Sprintf("fail to parse annotations in ingress: %s, namespace: %s", req.Name, req.Namespace))
+		klog.ErrorS(err, fmt.Sprintf("fail to parse annotations in ingress: %s, namespace: %s", ic.Name, ic.Namespace))
+		r.report(err)
 		return ctrl.Result{RequeueAfter: time.Second * time.Duration(15)}, nil
 	}
 
@@ -136,20 +174,27 @@ func (r *IngressReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 		ParsedAnnotations: parsed,
 	}
 
-	if err := NewNginxController(rs).GenerateConfigure(ings); err != nil {
-		klog.ErrorS(err, fmt.Sprintf("error in ingress: %s, namespace: %s", req.Name, req.Namespace))
+	nc := NewNginxController(rs)
+	if err := nc.GenerateConfigure(ings); err != nil {
+		klog.ErrorS(err, fmt.Sprintf("error in ingress: %s, namespace: %s", ic.Name, ic.Namespace))
+		r.report(err)
 		return ctrl.Result{RequeueAfter: time.Second * time.Duration(15)}, nil
 	}
 
+	r.conflicts = nc.Conflicts()
+	r.report(nil)
+
 	return ctrl.Result{}, nil
 }
 
 func (r *IngressReconciler) GetReconcileInfo() *store.IngressReconciler {
 	si := &store.IngressReconciler{
-		Client:  r.Client,
-		Scheme:  r.Scheme,
-		Ingress: r.ingress,
-		Context: r.ctx,
+		Client:   r.Client,
+		Scheme:   r.Scheme,
+		Ingress:  r.ingress,
+		Object:   r.object,
+		Context:  r.ctx,
+		Recorder: r.Recorder,
 	}
 
 	return si
@@ -162,7 +207,7 @@ func (r *IngressReconciler) checkService(key client.ObjectKey) error {
 	if err := r.Get(rs.Context, key, svc); err != nil {
 		if errors.IsNotFound(err) {
 			klog.ErrorS(err, fmt.Sprintf("no service with name %v found in namespace %v: %v", key.Name, key.Namespace, err))
-			return fmt.Errorf("no svc resource available")
+			return kerr.NewIsMissResourcesError(key.Name)
 
 		}
 		klog.ErrorS(err, fmt.Sprintf("unexpected error searching service with name %v in namespace %v: %v", key.Name, key.Namespace, err))
@@ -195,7 +240,7 @@ func (r *IngressReconciler) checkController() error {
 	getAnnotations := r.ingress.GetAnnotations()
 	if r.ingress.Spec.IngressClassName == "" && getAnnotations[nginxAnnotationKey] == "" {
 		klog.Infoln("the current controller can be used by adding ingressClass or annotating specified values")
-		return fmt.Errorf("select available ingress nginx controller")
+		return kerr.NewNotSatisfiableError("select available ingress nginx controller")
 	}
 
 	if r.ingress.Annotations[nginxAnnotationKey] == nginxAnnotationVal {
@@ -204,42 +249,87 @@ func (r *IngressReconciler) checkController() error {
 
 	key := types.NamespacedName{Name: r.ingress.Spec.IngressClassName, Namespace: r.ingress.Namespace}
 	if err := r.Get(r.ctx, key, ic); err != nil {
+		if errors.IsNotFound(err) {
+			return kerr.NewNotSatisfiableError(fmt.Sprintf("ingressClass %s not found", key.Name))
+		}
 		return err
 	}
 
 	if ic.Spec.Controller != controller {
 		klog.Infoln("neither ingressClass nor nginxAnnotationVal value matches the current controller")
-		return fmt.Errorf("pls select available ingress nginx controller")
+		return kerr.NewNotSatisfiableError(fmt.Sprintf("ingressClass %s is not served by %s", key.Name, controller))
 	}
 
 	return nil
 }
 
-func (r *IngressReconciler) clearConf(key client.ObjectKey) {
-	conf := filepath.Join(config.ConfDir, key.Name+"-"+key.Namespace+".conf")
-	if _, err := os.Stat(conf); err != nil {
-		defaultConf := strings.Split(config.MainConf, ".")
-		pr := &template_nginx.RenderTemplate{
-			GenerateName:       defaultConf[0],
-			RenderTemplateName: config.DefaultTmpl,
-			MainTemplateName:   config.NginxTmpl,
-		}
+// clearConf removes the configuration of a deleted ingress, the ones never applied have nothing to remove
+func (r *IngressReconciler) clearConf(ctx context.Context, key client.ObjectKey) {
+	metrics.DeleteIngress(key.Namespace, key.Name)
+
+	// the owners of the hosts its paths were merged into are requeued on their own reconciler
+	removed := applied.remove(key)
 
-		if err := NewConfHandler().UpdateDefaultConf(pr); err != nil {
-			return
+	configMux.Lock()
+	defer configMux.Unlock()
+
+	if removed.defaultBackend {
+		if err := NewConfHandler().UpdateDefaultConf(mainConfTemplate()); err != nil {
+			klog.ErrorS(err, fmt.Sprintf("fail to remove the default backend of ingress: %s, namespace: %s", key.Name, key.Namespace))
 		}
+	}
 
-		return
+	switch {
+	case removed.servers && len(removed.owners) > 0:
+		klog.Infof("keep the upstreams of ingress: %s, namespace: %s until the owners of its hosts render without them", key.Name, key.Namespace)
+	case removed.servers || removed.defaultBackend:
+		removeIngressFiles(key)
 	}
 
-	nginx.CleanConf(conf)
+	for _, k := range removed.released {
+		removeIngressFiles(k)
+	}
+}
+
+// removeIngressFiles removes the configuration file, the dynamic upstreams and the secret files of a deleted ingress
+func removeIngressFiles(key client.ObjectKey) {
+	nginx.CleanConf(filepath.Join(config.ConfDir, key.Name+"-"+key.Namespace+".conf"))
+	if err := nginx.DeleteBackends(key.String()); err != nil {
+		klog.ErrorS(err, fmt.Sprintf("fail to remove the dynamic upstreams of ingress: %s, namespace: %s", key.Name, key.Namespace))
+	}
+
+	prefix := filepath.Join(config.SslPath, key.Name+"-"+key.Namespace)
+	nginx.CleanConf(prefix+"-auth", prefix+"-ca.crt", prefix+"-proxy-ca.crt")
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *IngressReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if err := setupIndexes(context.Background(), mgr.GetFieldIndexer(), &ingressv1.Ingress{}, func(obj client.Object) *ingressv1.Ingress {
+		return obj.(*ingressv1.Ingress)
+	}); err != nil {
+		return err
+	}
+
+	// nginx starts with the global settings, the ingresses are rendered into it once reconciled
+	if err := NewConfHandler().RenderMainConf(); err != nil {
+		return err
+	}
 	go nginx.Start()
 	r.dynamicClient = r.createDynamicClientSet()
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&ingressv1.Ingress{}).
+		// status updates do not bump the generation, skip them to avoid reconciling our own writes
+		For(&ingressv1.Ingress{}, builder.WithPredicates(predicate.Or(
+			predicate.GenerationChangedPredicate{},
+			predicate.AnnotationChangedPredicate{},
+			predicate.LabelChangedPredicate{},
+		))).
+		// ready pods are rendered into the upstreams, so endpoint changes re-render the ingress
+		Watches(&discoveryv1.EndpointSlice{}, handler.EnqueueRequestsFromMapFunc(r.endpointSliceToIngresses)).
+		// ports of services, rotated certificates and the controller of the class all change the rendered configuration
+		Watches(&v1.Service{}, handler.EnqueueRequestsFromMapFunc(r.serviceToIngresses)).
+		Watches(&v1.Secret{}, handler.EnqueueRequestsFromMapFunc(r.secretToIngresses)).
+		Watches(&netv1.IngressClass{}, handler.EnqueueRequestsFromMapFunc(r.ingressClassToIngresses)).
+		// ingresses sharing a host with an applied or deleted one render its merged server blocks again
+		WatchesRawSource(source.Channel(applied.customEvents, &handler.EnqueueRequestForObject{})).
 		Complete(r)
 }
@@ -3,16 +3,23 @@ package controller
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	ingressv1 "github.com/Lxb921006/ingress-nginx-kubebuilder/api/v1"
 	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations"
 	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations/parser"
 	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations/resolver"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations/weight"
 	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/config"
 	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/controller/store"
+	kerr "github.com/Lxb921006/ingress-nginx-kubebuilder/internal/errors"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/metrics"
 	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/nginx"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/pkg/utils/file"
+	corev1 "k8s.io/api/core/v1"
 	netv1 "k8s.io/api/networking/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/klog/v2"
 	"os"
 	"path/filepath"
@@ -20,34 +27,49 @@ import (
 	"strings"
 	"sync"
 	"text/template"
+	"time"
 )
 
 type configure struct {
-	Server      *ingressv1.Server
-	Annotations *annotations.Ingress
-	ServerTpl   bytes.Buffer
-	Cfg         *ingressv1.Configuration
-	TmplName    string
-	MainTmpl    string
-	ConfName    string
+	Server           *ingressv1.Server
+	Annotations      *annotations.Ingress
+	ServerTpl        bytes.Buffer
+	Cfg              *ingressv1.Configuration
+	TmplName         string
+	MainTmpl         string
+	ConfName         string
+	Global           *config.Global
+	DynamicUpstreams bool
+	Upstreams        []upstream
+	Canaries         []canaryRoute
 }
 
 type NginxController struct {
-	client  client.Client
-	ctx     context.Context
-	rr      resolver.Resolver
-	mux     *sync.RWMutex
-	ingress *ingressv1.Ingress
+	client   client.Client
+	ctx      context.Context
+	rr       resolver.Resolver
+	mux      *sync.RWMutex
+	ingress  *ingressv1.Ingress
+	object   client.Object
+	recorder record.EventRecorder
+	// paths of the ingress shadowed by an older ingress declaring the same host and path
+	conflicts []string
 }
 
+// configMux serializes the rendering and reloading of every reconciler, a test file of one ingress
+// lying in conf.d would make nginx -t of another one fail
+var configMux = new(sync.RWMutex)
+
 func NewNginxController(store store.Storer) *NginxController {
 	st := store.ReconcilerInfo()
 	n := &NginxController{
-		client:  st.Client,
-		ctx:     st.Context,
-		rr:      st.IngressInfos,
-		ingress: st.Ingress,
-		mux:     new(sync.RWMutex),
+		client:   st.Client,
+		ctx:      st.Context,
+		rr:       st.IngressInfos,
+		ingress:  st.Ingress,
+		object:   st.Object,
+		recorder: st.Recorder,
+		mux:      configMux,
 	}
 
 	return n
@@ -96,6 +118,8 @@ func (n *NginxController) generateConf(name string, b []byte) error {
 
 // Generate a.conf file named after host
 func (n *NginxController) generateConfigureBytes(cfg *configure) error {
+	defer metrics.ObserveRender(n.ingress.Namespace, n.ingress.Name, time.Now())
+
 	mainTmplStr, err := os.ReadFile(cfg.MainTmpl)
 	if err != nil {
 		klog.ErrorS(err, fmt.Sprintf("tmpelate file: %s not found", cfg.MainTmpl))
@@ -125,7 +149,7 @@ func (n *NginxController) generateConfigureBytes(cfg *configure) error {
 	}
 
 	var tpl bytes.Buffer
-	if err = mainTmpl.Execute(&tpl, nil); err != nil {
+	if err = mainTmpl.Execute(&tpl, cfg); err != nil {
 		return err
 	}
 
@@ -140,6 +164,10 @@ func (n *NginxController) GenerateConfigure(ingress annotations.IngressAnnotatio
 	n.mux.Lock()
 	defer n.mux.Unlock()
 
+	if err := n.generateSecretFiles(ingress.ParsedAnnotations); err != nil {
+		return err
+	}
+
 	if len(n.ingress.Spec.Rules) > 0 {
 		if err := n.generateBackendTemplate(ingress); err != nil {
 			return err
@@ -155,18 +183,55 @@ func (n *NginxController) GenerateConfigure(ingress annotations.IngressAnnotatio
 	return nil
 }
 
+// Conflicts returns the paths of the ingress served by an older ingress sharing the host
+func (n *NginxController) Conflicts() []string {
+	return n.conflicts
+}
+
 func (n *NginxController) generateBackendTemplate(ingress annotations.IngressAnnotations) error {
 	serversCfg, err := n.getBackendConfigure(ingress)
 	if err != nil {
 		return err
 	}
 
+	ups, err := n.buildUpstreams(serversCfg.Servers, ingress.ParsedAnnotations)
+	if err != nil {
+		return err
+	}
+
+	_, core := n.object.(*netv1.Ingress)
+	self := &appliedIngress{
+		key:         client.ObjectKeyFromObject(n.ingress),
+		core:        core,
+		created:     n.ingress.CreationTimestamp,
+		servers:     serversCfg.Servers,
+		annotations: serverAnnotationsOf(n.ingress),
+	}
+	if ingress.ParsedAnnotations.Canary.Enabled {
+		self.canary = &ingress.ParsedAnnotations.Canary
+	}
+
+	// hosts shared with older ingresses are rendered by them, the younger ones are merged into ours
+	servers, canaries, conflicts := applied.merge(self)
+	for _, c := range conflicts {
+		klog.Warningf("ingress: %s, namespace: %s, %s", n.ingress.Name, n.ingress.Namespace, c)
+	}
+	n.conflicts = conflicts
+	for _, s := range servers {
+		self.rendered = append(self.rendered, s.HostName)
+	}
+
+	global := config.GetGlobal()
 	cfg := &configure{
-		Cfg:         serversCfg,
-		Annotations: ingress.ParsedAnnotations,
-		TmplName:    config.ServerTmpl,
-		MainTmpl:    config.MainServerTmpl,
-		ConfName:    filepath.Join(config.ConfDir, n.ingress.Name+"-"+n.ingress.Namespace),
+		Cfg:              &ingressv1.Configuration{Servers: servers},
+		Upstreams:        ups,
+		Canaries:         canaries,
+		Annotations:      ingress.ParsedAnnotations,
+		TmplName:         config.ServerTmpl,
+		MainTmpl:         config.MainServerTmpl,
+		ConfName:         filepath.Join(config.ConfDir, n.ingress.Name+"-"+n.ingress.Namespace),
+		Global:           global,
+		DynamicUpstreams: global.DynamicUpstreams && !ingress.ParsedAnnotations.Upstream.ServiceUpstream,
 	}
 
 	if err := n.generateConfigureBytes(cfg); err != nil {
@@ -175,10 +240,22 @@ func (n *NginxController) generateBackendTemplate(ingress annotations.IngressAnn
 
 	klog.Infof("update %s-%s.conf successfully", n.ingress.Name, n.ingress.Namespace)
 
-	if err := nginx.Reload(cfg.ConfName); err != nil {
+	if err := n.reload(cfg); err != nil {
 		return err
 	}
 
+	// balancer.lua answers 503 for the upstreams of the loaded configuration until their peers are pushed
+	if err := n.pushBackends(cfg); err != nil {
+		return err
+	}
+
+	applied.set(self)
+
+	// the deleted ingresses whose paths the ingress rendered are no longer referenced
+	for _, key := range applied.release(self.key) {
+		removeIngressFiles(key)
+	}
+
 	return nil
 }
 
@@ -196,6 +273,7 @@ func (n *NginxController) generateDefaultBackendTemplate(ingress annotations.Ing
 		TmplName:    config.DefaultTmpl,
 		MainTmpl:    config.NginxTmpl,
 		ConfName:    conf[0],
+		Global:      config.GetGlobal(),
 	}
 
 	if err := n.generateConfigureBytes(cfg); err != nil {
@@ -204,13 +282,54 @@ func (n *NginxController) generateDefaultBackendTemplate(ingress annotations.Ing
 
 	klog.Info(fmt.Sprintf("update %s successfully", filepath.Base(config.MainConf)))
 
-	if err := nginx.Reload(cfg.ConfName); err != nil {
+	if err := n.reload(cfg); err != nil {
+		return err
+	}
+
+	applied.setDefaultBackend(client.ObjectKeyFromObject(n.ingress), &defaultConf{
+		Server:      defaultCfg.Servers[0],
+		Annotations: ingress.ParsedAnnotations,
+	})
+
+	return nil
+}
+
+// reload applies the generated configuration and records the outcome as events and metrics of the ingress.
+func (n *NginxController) reload(cfg *configure) error {
+	reloaded, err := nginx.Reload(cfg.ConfName)
+
+	var testErr kerr.NginxTestError
+	rolledBack := errors.As(err, &testErr) && testErr.RolledBack
+	metrics.ObserveReload(n.ingress.Namespace, n.ingress.Name, reloaded, rolledBack, err)
+
+	if err != nil {
+		if rolledBack {
+			n.recorder.Eventf(n.object, corev1.EventTypeWarning, "RollbackPerformed",
+				"nginx -t failed, restored the previous %s", testErr.Conf)
+		}
 		return err
 	}
 
+	if reloaded {
+		n.recorder.Eventf(n.object, corev1.EventTypeNormal, "Reloaded", "nginx reloaded with %s.conf", filepath.Base(cfg.ConfName))
+	}
+
+	metrics.SetConfigState(n.ingress.Namespace, n.ingress.Name,
+		len(cfg.Cfg.Servers), n.upstreams(cfg), file.SHA1(cfg.ConfName+".conf"))
+
 	return nil
 }
 
+// upstreams returns the upstream blocks rendered for the ingress, keyed by name, with the service(s) behind them
+func (n *NginxController) upstreams(cfg *configure) map[string]string {
+	names := make(map[string]string)
+	for _, up := range cfg.Upstreams {
+		names[up.Name] = up.Service
+	}
+
+	return names
+}
+
 func (n *NginxController) getDefaultBackendConfigure(ingress annotations.IngressAnnotations) (*ingressv1.Configuration, error) {
 	var servers []*ingressv1.Server
 	var backends []*ingressv1.Backend
@@ -257,6 +376,11 @@ func (n *NginxController) getBackendConfigure(ingress annotations.IngressAnnotat
 		klog.Warningf(fmt.Sprintf("failed to generate certificate and will not be able to use https"))
 	}
 
+	if ingress.ParsedAnnotations.Weight.UseWeight {
+		weights := &ingress.ParsedAnnotations.Weight
+		weights.Endpoints = n.weightedEndpoints(weights.Services, ingress.ParsedAnnotations)
+	}
+
 	for k, v := range rule {
 		var backendLen = len(v.HTTP.Paths)
 		var ingressPaths []netv1.HTTPIngressPath
@@ -288,9 +412,10 @@ func (n *NginxController) getBackendConfigure(ingress annotations.IngressAnnotat
 				IngName:        n.ingress.Name,
 				Name:           svc.Name,
 				NameSpace:      svc.Namespace,
-				Path:           n.formatPath(p.Path, ingress),
+				Path:           n.formatPath(&p, ingress),
 				TargetPath:     p.Path,
 				Port:           backendPort,
+				Endpoints:      n.getEndpoints(svc, p.Backend.Service.Port, ingress.ParsedAnnotations),
 				ServiceBackend: p.Backend.Service,
 				Annotations:    ingress.ParsedAnnotations,
 			}
@@ -311,6 +436,107 @@ func (n *NginxController) getBackendConfigure(ingress annotations.IngressAnnotat
 	return &ingressv1.Configuration{Servers: servers}, nil
 }
 
+// getEndpoints returns the ready pods behind a backend, nothing means the upstream points at the ClusterIP of the service
+func (n *NginxController) getEndpoints(svc *corev1.Service, port netv1.ServiceBackendPort, anns *annotations.Ingress) []string {
+	if anns.Upstream.ServiceUpstream {
+		return nil
+	}
+
+	endpoints, err := n.rr.GetEndpoints(svc, port)
+	if err != nil {
+		klog.Warningf("%v, falling back to the ClusterIP of the service", err)
+		return nil
+	}
+
+	if len(endpoints) == 0 {
+		klog.Warningf("service: %s has no ready endpoints in namespace: %s, falling back to its ClusterIP", svc.Name, svc.Namespace)
+	}
+
+	return endpoints
+}
+
+// weightedEndpoints spreads the weight of every service of set-weight across its ready pods,
+// so that the share of traffic of a service does not depend on its number of pods
+func (n *NginxController) weightedEndpoints(services []weight.WeightedService, anns *annotations.Ingress) []weight.WeightedEndpoint {
+	var list []weight.WeightedEndpoint
+	for _, ws := range services {
+		var endpoints []string
+		svc, err := n.rr.GetService(ws.Name)
+		if err == nil {
+			endpoints = n.getEndpoints(svc, netv1.ServiceBackendPort{Number: ws.Port}, anns)
+		}
+
+		if len(endpoints) == 0 {
+			if svc != nil {
+				endpoints = []string{serviceAddress(svc, ws.Port)}
+			} else {
+				endpoints = []string{fmt.Sprintf("%s.%s.svc:%d", ws.Name, ws.NameSpace, ws.Port)}
+			}
+		}
+
+		for _, ep := range endpoints {
+			w := 0
+			if ws.Weight > 0 {
+				w = ws.Weight * 100 / len(endpoints)
+				if w < 1 {
+					w = 1
+				}
+			}
+			list = append(list, weight.WeightedEndpoint{Address: ep, Weight: w})
+		}
+	}
+
+	return list
+}
+
+// generateSecretFiles writes the htpasswd entries of auth-secret and the ca bundles of auth-tls-secret
+// and proxy-ssl-secret next to the certificates of the ingress, and removes the ones it no longer references
+func (n *NginxController) generateSecretFiles(anns *annotations.Ingress) error {
+	prefix := filepath.Join(config.SslPath, n.ingress.Name+"-"+n.ingress.Namespace)
+
+	if anns.Auth.Type != "" {
+		if err := writeSecretFile(prefix+"-auth", anns.Auth.Htpasswd, 0600); err != nil {
+			klog.ErrorS(err, fmt.Sprintf("fail to write the htpasswd file of ingress: %s, namespace: %s", n.ingress.Name, n.ingress.Namespace))
+			return err
+		}
+		anns.Auth.File = prefix + "-auth"
+	} else {
+		nginx.CleanConf(prefix + "-auth")
+	}
+
+	if anns.AuthTLS.Secret != "" {
+		if err := writeSecretFile(prefix+"-ca.crt", anns.AuthTLS.CACert, 0644); err != nil {
+			klog.ErrorS(err, fmt.Sprintf("fail to write the client ca of ingress: %s, namespace: %s", n.ingress.Name, n.ingress.Namespace))
+			return err
+		}
+		anns.AuthTLS.CAFile = prefix + "-ca.crt"
+	} else {
+		nginx.CleanConf(prefix + "-ca.crt")
+	}
+
+	if anns.BackendProtocol.Secret != "" {
+		if err := writeSecretFile(prefix+"-proxy-ca.crt", anns.BackendProtocol.CACert, 0644); err != nil {
+			klog.ErrorS(err, fmt.Sprintf("fail to write the backend ca of ingress: %s, namespace: %s", n.ingress.Name, n.ingress.Namespace))
+			return err
+		}
+		anns.BackendProtocol.CAFile = prefix + "-proxy-ca.crt"
+	} else {
+		nginx.CleanConf(prefix + "-proxy-ca.crt")
+	}
+
+	return nil
+}
+
+// writeSecretFile writes the content of a secret only when it changed, the watcher of the ssl directory
+// reloads nginx on every write so that the ca bundles, only read on reload, follow the secrets
+func writeSecretFile(name string, data []byte, perm os.FileMode) error {
+	if cur, err := os.ReadFile(name); err == nil && bytes.Equal(cur, data) {
+		return nil
+	}
+
+	return os.WriteFile(name, data, perm)
+}
+
 func (n *NginxController) generateTlsFile() (map[string]ingressv1.SSLCert, error) {
 	if len(n.ingress.Spec.TLS) > 0 {
 		return n.generateCaTlsFile()
@@ -387,12 +613,18 @@ func (n *NginxController) generateCaTlsFile() (map[string]ingressv1.SSLCert, err
 	return ht, nil
 }
 
-func (n *NginxController) formatPath(path string, ingress annotations.IngressAnnotations) string {
+// formatPath returns the location of the path, an Exact path only matches itself, so a Prefix path
+// of another ingress with the same value is a different location
+func (n *NginxController) formatPath(p *netv1.HTTPIngressPath, ingress annotations.IngressAnnotations) string {
 	if ingress.ParsedAnnotations.Rewrite.EnableRegex || ingress.ParsedAnnotations.Rewrite.RewriteTarget != "" {
-		path = "~ ^" + path
+		return "~ ^" + p.Path
+	}
+
+	if p.PathType != nil && *p.PathType == netv1.PathTypeExact {
+		return "= " + p.Path
 	}
 
-	return path
+	return p.Path
 }
 
 func (n *NginxController) checkIngressContent(path *netv1.HTTPIngressPath, annotations *annotations.Ingress) error {
@@ -0,0 +1,140 @@
+package controller
+
+import (
+	"fmt"
+	ingressv1 "github.com/Lxb921006/ingress-nginx-kubebuilder/api/v1"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/config"
+	kerr "github.com/Lxb921006/ingress-nginx-kubebuilder/internal/errors"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/pkg/utils/file"
+	corev1 "k8s.io/api/core/v1"
+	netv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	podIPEnv = "POD_IP"
+	// reasonHostPathConflict marks an ingress declaring a host and path already served by an older ingress
+	reasonHostPathConflict = "HostPathConflict"
+)
+
+// report records the outcome of the current reconcile as a Warning event when it failed
+// and on the ingress status subresource.
+func (r *IngressReconciler) report(reconcileErr error) {
+	if reconcileErr != nil {
+		r.Recorder.Event(r.object, corev1.EventTypeWarning, kerr.Reason(reconcileErr), reconcileErr.Error())
+	} else if len(r.conflicts) > 0 {
+		r.Recorder.Event(r.object, corev1.EventTypeWarning, reasonHostPathConflict, strings.Join(r.conflicts, "; "))
+	}
+
+	if ing, ok := r.object.(*netv1.Ingress); ok {
+		r.updateCoreStatus(ing)
+		return
+	}
+
+	r.updateStatus(reconcileErr)
+}
+
+// updateCoreStatus publishes the load balancer addresses on a networking.k8s.io/v1 Ingress,
+// whose status has no room for conditions
+func (r *IngressReconciler) updateCoreStatus(ing *netv1.Ingress) {
+	lb := r.loadBalancerStatus()
+	if equality.Semantic.DeepEqual(lb, ing.Status.LoadBalancer) {
+		return
+	}
+
+	ing.Status.LoadBalancer = lb
+	if err := r.Status().Update(r.ctx, ing); err != nil {
+		klog.ErrorS(err, fmt.Sprintf("fail to update status of ingress: %s, namespace: %s", ing.Name, ing.Namespace))
+	}
+}
+
+// updateStatus records the outcome of the current reconcile on the ingress status subresource.
+// A nil reconcileErr means the configuration was rendered, tested and reloaded successfully.
+func (r *IngressReconciler) updateStatus(reconcileErr error) {
+	ic := r.ingress
+	status := ic.Status.DeepCopy()
+	status.ObservedGeneration = ic.Generation
+	status.LoadBalancer = r.loadBalancerStatus()
+
+	reason := kerr.Reason(reconcileErr)
+	if reconcileErr == nil {
+		status.ConfigHash = file.SHA1(r.confFile())
+		r.setCondition(status, ingressv1.ConditionConfigApplied, metav1.ConditionTrue, "ConfigApplied",
+			fmt.Sprintf("%s passed nginx -t and nginx has been reloaded", filepath.Base(r.confFile())))
+		r.setCondition(status, ingressv1.ConditionReady, metav1.ConditionTrue, reason, "ingress is served by nginx")
+		if len(r.conflicts) > 0 {
+			r.setCondition(status, ingressv1.ConditionDegraded, metav1.ConditionTrue, reasonHostPathConflict, strings.Join(r.conflicts, "; "))
+		} else {
+			r.setCondition(status, ingressv1.ConditionDegraded, metav1.ConditionFalse, reason, "")
+		}
+	} else {
+		msg := reconcileErr.Error()
+		r.setCondition(status, ingressv1.ConditionConfigApplied, metav1.ConditionFalse, reason, msg)
+		r.setCondition(status, ingressv1.ConditionReady, metav1.ConditionFalse, reason, msg)
+		r.setCondition(status, ingressv1.ConditionDegraded, metav1.ConditionTrue, reason, msg)
+	}
+
+	if equality.Semantic.DeepEqual(status, &ic.Status) {
+		return
+	}
+
+	ic.Status = *status
+	if err := r.Status().Update(r.ctx, ic); err != nil {
+		klog.ErrorS(err, fmt.Sprintf("fail to update status of ingress: %s, namespace: %s", ic.Name, ic.Namespace))
+	}
+}
+
+func (r *IngressReconciler) setCondition(status *ingressv1.IngressStatus, conditionType string, s metav1.ConditionStatus, reason, msg string) {
+	meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+		Type:               conditionType,
+		Status:             s,
+		ObservedGeneration: r.ingress.Generation,
+		Reason:             reason,
+		Message:            msg,
+	})
+}
+
+// loadBalancerStatus returns the addresses configured with --publish-status-address,
+// falling back to the ip of the controller pod, and none for an ingress the controller does not serve.
+func (r *IngressReconciler) loadBalancerStatus() netv1.IngressLoadBalancerStatus {
+	var lb netv1.IngressLoadBalancerStatus
+	if !r.served {
+		return lb
+	}
+
+	addresses := r.PublishStatusAddress
+	if addresses == "" {
+		addresses = os.Getenv(podIPEnv)
+	}
+
+	for _, addr := range strings.Split(addresses, ",") {
+		addr = strings.TrimSpace(addr)
+		if addr == "" {
+			continue
+		}
+
+		if net.ParseIP(addr) != nil {
+			lb.Ingress = append(lb.Ingress, netv1.IngressLoadBalancerIngress{IP: addr})
+		} else {
+			lb.Ingress = append(lb.Ingress, netv1.IngressLoadBalancerIngress{Hostname: addr})
+		}
+	}
+
+	return lb
+}
+
+// confFile returns the nginx configuration file generated for the ingress.
+func (r *IngressReconciler) confFile() string {
+	if len(r.ingress.Spec.Rules) > 0 {
+		return filepath.Join(config.ConfDir, r.ingress.Name+"-"+r.ingress.Namespace+".conf")
+	}
+
+	return config.MainConf
+}
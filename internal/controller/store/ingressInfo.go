@@ -6,11 +6,15 @@ import (
 	ingressv1 "github.com/Lxb921006/ingress-nginx-kubebuilder/api/v1"
 	utils "github.com/Lxb921006/ingress-nginx-kubebuilder/pkg/utils/cert"
 	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
 	netv1 "k8s.io/api/networking/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/klog/v2"
+	"net"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"strconv"
 )
 
 type IngressInfo struct {
@@ -152,3 +156,44 @@ func (t *IngressInfo) GetTlsData(key client.ObjectKey) (map[string][]byte, error
 
 	return data, nil
 }
+
+// GetEndpoints returns the ready ip:port pairs behind the given port of the service, sorted
+func (t *IngressInfo) GetEndpoints(svc *corev1.Service, port netv1.ServiceBackendPort) ([]string, error) {
+	var svcPort *corev1.ServicePort
+	for i, p := range svc.Spec.Ports {
+		if (port.Number != 0 && p.Port == port.Number) || (port.Name != "" && p.Name == port.Name) {
+			svcPort = &svc.Spec.Ports[i]
+			break
+		}
+	}
+
+	if svcPort == nil {
+		return nil, fmt.Errorf("service: %s has no port %v in namespace: %s", svc.Name, port, svc.Namespace)
+	}
+
+	slices := new(discoveryv1.EndpointSliceList)
+	if err := t.r.List(t.ctx, slices, client.InNamespace(svc.Namespace),
+		client.MatchingLabels{discoveryv1.LabelServiceName: svc.Name}); err != nil {
+		return nil, fmt.Errorf("unexpected error listing endpointslices of service %v in namespace %v: %v", svc.Name, svc.Namespace, err)
+	}
+
+	endpoints := sets.NewString()
+	for _, slice := range slices.Items {
+		for _, p := range slice.Ports {
+			if p.Port == nil || (p.Name != nil && *p.Name != svcPort.Name) || (p.Name == nil && svcPort.Name != "") {
+				continue
+			}
+
+			for _, ep := range slice.Endpoints {
+				if ep.Conditions.Ready != nil && !*ep.Conditions.Ready {
+					continue
+				}
+				for _, addr := range ep.Addresses {
+					endpoints.Insert(net.JoinHostPort(addr, strconv.Itoa(int(*p.Port))))
+				}
+			}
+		}
+	}
+
+	return endpoints.List(), nil
+}
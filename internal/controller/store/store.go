@@ -5,6 +5,7 @@ import (
 	ingressv1 "github.com/Lxb921006/ingress-nginx-kubebuilder/api/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
@@ -18,9 +19,11 @@ type IngressReconciler struct {
 	Client           client.Client
 	Scheme           *runtime.Scheme
 	Ingress          *ingressv1.Ingress
+	Object           client.Object
 	Context          context.Context
 	IngressInfos     *IngressInfo
 	DynamicClientSet *dynamic.DynamicClient
+	Recorder         record.EventRecorder
 }
 
 func (i *IngressReconciler) ReconcilerInfo() *IngressReconciler {
@@ -0,0 +1,205 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/config"
+	kerr "github.com/Lxb921006/ingress-nginx-kubebuilder/internal/errors"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/nginx"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/klog/v2"
+	"os"
+	"path/filepath"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// StreamReconciler proxies the tcp and udp services of its ConfigMaps, the ports of the ConfigMaps map to
+// a namespace/service:port, e.g: `53: kube-system/kube-dns:53`
+type StreamReconciler struct {
+	client.Client
+	Recorder record.EventRecorder
+	// TCPServices and UDPServices are the namespace/name of the ConfigMaps, empty when not proxied
+	TCPServices types.NamespacedName
+	UDPServices types.NamespacedName
+}
+
+// streamConfigMap is a ConfigMap of the services of a protocol
+type streamConfigMap struct {
+	protocol string
+	key      types.NamespacedName
+}
+
+//+kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch
+//+kubebuilder:rbac:groups=core,resources=services,verbs=get;list;watch
+
+func (r *StreamReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	var services []config.StreamService
+	var cms []*corev1.ConfigMap
+
+	for _, ref := range r.configMaps() {
+		cm := new(corev1.ConfigMap)
+		if err := r.Get(ctx, ref.key, cm); err != nil {
+			if !apierrors.IsNotFound(err) {
+				return ctrl.Result{}, err
+			}
+			klog.Infof("configmap %s not found, no %s services are proxied", ref.key, ref.protocol)
+			continue
+		}
+
+		parsed, err := config.ParseStreamServices(ref.protocol, cm.Data)
+		if err != nil {
+			klog.ErrorS(err, fmt.Sprintf("fail to parse configmap %s, keeping the current stream services", ref.key))
+			r.Recorder.Event(cm, corev1.EventTypeWarning, kerr.Reason(err), err.Error())
+			return ctrl.Result{}, nil
+		}
+
+		// a missing service would fail nginx -t of every configuration, it is left out until it exists
+		for _, svc := range parsed {
+			if svc.Address, err = r.address(ctx, svc); err != nil {
+				klog.ErrorS(err, fmt.Sprintf("%s port %d of configmap %s is not proxied", svc.Protocol, svc.Port, ref.key))
+				r.Recorder.Eventf(cm, corev1.EventTypeWarning, "ServiceNotFound", "%s port %d is not proxied: %v", svc.Protocol, svc.Port, err)
+				continue
+			}
+			services = append(services, svc)
+		}
+		cms = append(cms, cm)
+	}
+
+	reloaded, err := r.apply(services)
+	if err != nil {
+		var testErr kerr.NginxTestError
+		if errors.As(err, &testErr) && testErr.RolledBack {
+			r.event(cms, corev1.EventTypeWarning, "RollbackPerformed", fmt.Sprintf("nginx -t failed, restored the previous %s", testErr.Conf))
+		}
+		klog.ErrorS(err, "fail to apply the stream services, keeping the current settings")
+		r.event(cms, corev1.EventTypeWarning, kerr.Reason(err), err.Error())
+		return ctrl.Result{}, err
+	}
+
+	if reloaded {
+		r.event(cms, corev1.EventTypeNormal, "Reloaded", fmt.Sprintf("nginx reloaded with %d stream services", len(services)))
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// apply renders the stream services and reloads nginx, restoring the previous services when nginx -t fails
+func (r *StreamReconciler) apply(services []config.StreamService) (bool, error) {
+	configMux.Lock()
+	defer configMux.Unlock()
+
+	// nginx -t passes with any services until nginx.conf includes them
+	main, err := os.ReadFile(config.MainConf)
+	if err != nil {
+		return false, err
+	}
+	if !bytes.Contains(main, []byte("include "+filepath.Join(config.StreamDir, "*.conf")+";")) {
+		return false, fmt.Errorf("%s does not include the stream services of %s", config.MainConf, config.StreamDir)
+	}
+
+	tmpl, err := template.ParseFiles(config.StreamTmpl)
+	if err != nil {
+		klog.ErrorS(err, fmt.Sprintf("error parsing template_nginx: %s", config.StreamTmpl))
+		return false, err
+	}
+
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, struct{ Services []config.StreamService }{services}); err != nil {
+		return false, err
+	}
+
+	if err := os.MkdirAll(config.StreamDir, 0755); err != nil {
+		return false, err
+	}
+
+	name := filepath.Join(config.StreamDir, config.StreamConf)
+	if err := os.WriteFile(name+"-test.conf", b.Bytes(), 0644); err != nil {
+		klog.ErrorS(err, fmt.Sprintf("an error occurred while writing the generated content to %s-test.conf", name))
+		return false, err
+	}
+
+	return nginx.Reload(name)
+}
+
+// address returns the dns name and port of the service of s, the port is matched by number or name and protocol
+func (r *StreamReconciler) address(ctx context.Context, s config.StreamService) (string, error) {
+	svc := new(corev1.Service)
+	if err := r.Get(ctx, types.NamespacedName{Namespace: s.Namespace, Name: s.Service}, svc); err != nil {
+		return "", err
+	}
+
+	for _, port := range svc.Spec.Ports {
+		if port.Name != s.ServicePort && strconv.Itoa(int(port.Port)) != s.ServicePort {
+			continue
+		}
+		if strings.EqualFold(string(port.Protocol), s.Protocol) {
+			return fmt.Sprintf("%s.%s.svc:%d", svc.Name, svc.Namespace, port.Port), nil
+		}
+	}
+
+	return "", fmt.Errorf("service %s/%s has no %s port %s", s.Namespace, s.Service, s.Protocol, s.ServicePort)
+}
+
+func (r *StreamReconciler) configMaps() []streamConfigMap {
+	var refs []streamConfigMap
+	for _, ref := range []streamConfigMap{
+		{config.ProtocolTCP, r.TCPServices},
+		{config.ProtocolUDP, r.UDPServices},
+	} {
+		if ref.key.Name != "" {
+			refs = append(refs, ref)
+		}
+	}
+
+	return refs
+}
+
+// serviceToStreams enqueues the stream services when one of them points to the service
+func (r *StreamReconciler) serviceToStreams(ctx context.Context, obj client.Object) []reconcile.Request {
+	prefix := obj.GetNamespace() + "/" + obj.GetName() + ":"
+	for _, ref := range r.configMaps() {
+		cm := new(corev1.ConfigMap)
+		if err := r.Get(ctx, ref.key, cm); err != nil {
+			continue
+		}
+		for _, val := range cm.Data {
+			if strings.HasPrefix(strings.TrimSpace(val), prefix) {
+				return []reconcile.Request{{NamespacedName: ref.key}}
+			}
+		}
+	}
+
+	return nil
+}
+
+func (r *StreamReconciler) event(cms []*corev1.ConfigMap, eventType, reason, msg string) {
+	for _, cm := range cms {
+		r.Recorder.Event(cm, eventType, reason, msg)
+	}
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *StreamReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("stream").
+		For(&corev1.ConfigMap{}, builder.WithPredicates(predicate.NewPredicateFuncs(func(obj client.Object) bool {
+			key := client.ObjectKeyFromObject(obj)
+			return key == r.TCPServices || key == r.UDPServices
+		}))).
+		// the services are proxied by their dns name, which must resolve for nginx -t to pass
+		Watches(&corev1.Service{}, handler.EnqueueRequestsFromMapFunc(r.serviceToStreams)).
+		Complete(r)
+}
@@ -0,0 +1,165 @@
+package controller
+
+import (
+	"context"
+	ingressv1 "github.com/Lxb921006/ingress-nginx-kubebuilder/api/v1"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/annotations/parser"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	netv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog/v2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"strings"
+)
+
+const (
+	setWeightAnnotation      = "set-weight"
+	authSecretAnnotation     = "auth-secret"
+	authTLSSecretAnnotation  = "auth-tls-secret"
+	proxySSLSecretAnnotation = "proxy-ssl-secret"
+	defaultBackendAnnotation = "default-backend"
+)
+
+// field indexes of the ingress cache, used to find the ingresses depending on a changed object
+const (
+	serviceIndex      = "ingress.services"
+	secretIndex       = "ingress.secrets"
+	ingressClassIndex = "ingress.ingressClass"
+)
+
+// setupIndexes registers the field indexes the watches of the reconciler look ingresses up with,
+// convert turns the indexed object into the ingress the references are read from
+func setupIndexes(ctx context.Context, indexer client.FieldIndexer, obj client.Object, convert func(client.Object) *ingressv1.Ingress) error {
+	indexes := map[string]func(*ingressv1.Ingress) []string{
+		serviceIndex:      referencedServices,
+		secretIndex:       referencedSecrets,
+		ingressClassIndex: referencedIngressClass,
+	}
+
+	for field, extract := range indexes {
+		if err := indexer.IndexField(ctx, obj, field, func(o client.Object) []string {
+			return extract(convert(o))
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// referencedServices returns the names of the services an ingress sends traffic to
+func referencedServices(ing *ingressv1.Ingress) []string {
+	services := sets.NewString()
+
+	if ing.Spec.DefaultBackend != nil && ing.Spec.DefaultBackend.Service != nil {
+		services.Insert(ing.Spec.DefaultBackend.Service.Name)
+	}
+
+	for _, rule := range ing.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+		for _, p := range rule.HTTP.Paths {
+			if p.Backend.Service != nil {
+				services.Insert(p.Backend.Service.Name)
+			}
+		}
+	}
+
+	// e.g. svc-name1:weight=80,svc-name2:weight=20
+	for _, alias := range strings.Split(ing.GetAnnotations()[parser.GetAnnotationWithPrefix(setWeightAnnotation)], ",") {
+		if name := strings.TrimSpace(strings.Split(alias, ":")[0]); name != "" {
+			services.Insert(name)
+		}
+	}
+
+	// e.g. error-pages:8080
+	if name, _, _ := strings.Cut(ing.GetAnnotations()[parser.GetAnnotationWithPrefix(defaultBackendAnnotation)], ":"); name != "" {
+		services.Insert(name)
+	}
+
+	return services.List()
+}
+
+// referencedSecrets returns the names of the tls secrets of an ingress, including the <name>-secret
+// issued by cert-manager when spec.tls is empty, and of the secrets of its annotations
+func referencedSecrets(ing *ingressv1.Ingress) []string {
+	secrets := sets.NewString(ing.Name + "-secret")
+
+	for _, key := range []string{authSecretAnnotation, authTLSSecretAnnotation, proxySSLSecretAnnotation} {
+		if name := ing.GetAnnotations()[parser.GetAnnotationWithPrefix(key)]; name != "" {
+			secrets.Insert(name)
+		}
+	}
+
+	for _, tls := range ing.Spec.TLS {
+		if tls.SecretName != "" {
+			secrets.Insert(tls.SecretName)
+		}
+	}
+
+	return secrets.List()
+}
+
+// referencedIngressClass returns the IngressClass selected by spec.ingressClassName
+func referencedIngressClass(ing *ingressv1.Ingress) []string {
+	if ing.Spec.IngressClassName == "" {
+		return nil
+	}
+
+	return []string{ing.Spec.IngressClassName}
+}
+
+// serviceToIngresses enqueues the ingresses of the namespace sending traffic to a service
+func (r *IngressReconciler) serviceToIngresses(ctx context.Context, obj client.Object) []reconcile.Request {
+	return r.ingressesByIndex(ctx, serviceIndex, obj.GetName(), client.InNamespace(obj.GetNamespace()))
+}
+
+// endpointSliceToIngresses enqueues the ingresses of the namespace sending traffic to the service of an EndpointSlice
+func (r *IngressReconciler) endpointSliceToIngresses(ctx context.Context, obj client.Object) []reconcile.Request {
+	svc := obj.GetLabels()[discoveryv1.LabelServiceName]
+	if svc == "" {
+		return nil
+	}
+
+	return r.ingressesByIndex(ctx, serviceIndex, svc, client.InNamespace(obj.GetNamespace()))
+}
+
+// secretToIngresses enqueues the ingresses of the namespace terminating tls with a secret
+func (r *IngressReconciler) secretToIngresses(ctx context.Context, obj client.Object) []reconcile.Request {
+	return r.ingressesByIndex(ctx, secretIndex, obj.GetName(), client.InNamespace(obj.GetNamespace()))
+}
+
+// ingressClassToIngresses enqueues the ingresses of every namespace selecting an IngressClass
+func (r *IngressReconciler) ingressClassToIngresses(ctx context.Context, obj client.Object) []reconcile.Request {
+	return r.ingressesByIndex(ctx, ingressClassIndex, obj.GetName())
+}
+
+func (r *IngressReconciler) ingressesByIndex(ctx context.Context, field, value string, opts ...client.ListOption) []reconcile.Request {
+	var ings client.ObjectList = new(ingressv1.IngressList)
+	if r.coreIngress {
+		ings = new(netv1.IngressList)
+	}
+
+	if err := r.List(ctx, ings, append(opts, client.MatchingFields{field: value})...); err != nil {
+		klog.ErrorS(err, "fail to list ingresses", "index", field, "value", value)
+		return nil
+	}
+
+	var requests []reconcile.Request
+	if err := meta.EachListItem(ings, func(obj runtime.Object) error {
+		ing := obj.(client.Object)
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Name: ing.GetName(), Namespace: ing.GetNamespace()},
+		})
+		return nil
+	}); err != nil {
+		klog.ErrorS(err, "fail to read listed ingresses", "index", field, "value", value)
+	}
+
+	return requests
+}
@@ -3,6 +3,7 @@ package errors
 import (
 	"errors"
 	"fmt"
+	"strings"
 )
 
 var (
@@ -102,6 +103,27 @@ func NewInvalidAnnotationsContentError(name string, val interface{}) error {
 	}
 }
 
+// InvalidConfigurationError is returned when a key of the global ConfigMap has an invalid value
+type InvalidConfigurationError struct {
+	Name string
+}
+
+func (e InvalidConfigurationError) Error() string {
+	return e.Name
+}
+
+func IsInvalidConfigurationError(e error) bool {
+	var invalidConfigurationError InvalidConfigurationError
+	ok := errors.As(e, &invalidConfigurationError)
+	return ok
+}
+
+func NewInvalidConfigurationError(key string, val interface{}) error {
+	return InvalidConfigurationError{
+		Name: fmt.Sprintf("the key %v of the configmap does not contain a valid value (%v)", key, val),
+	}
+}
+
 type MissResourcesError struct {
 	Name string
 }
@@ -141,3 +163,56 @@ func NewNotSatisfiableError(msg string) error {
 		Msg: msg,
 	}
 }
+
+type NginxTestError struct {
+	Conf       string
+	Output     string
+	RolledBack bool
+}
+
+func (e NginxTestError) Error() string {
+	return fmt.Sprintf("nginx configuration %s failed verification: %s", e.Conf, e.Output)
+}
+
+func IsNginxTestError(e error) bool {
+	var nginxTestError NginxTestError
+	ok := errors.As(e, &nginxTestError)
+	return ok
+}
+
+func NewNginxTestError(conf string, output []byte, rolledBack bool) error {
+	return NginxTestError{
+		Conf:       conf,
+		Output:     strings.TrimSpace(string(output)),
+		RolledBack: rolledBack,
+	}
+}
+
+// Reason returns a CamelCase reason describing err, suitable for status conditions.
+func Reason(e error) string {
+	var riskyAnnotationError RiskyAnnotationError
+	var invalidContentError InvalidContentError
+
+	switch {
+	case e == nil:
+		return "Reconciled"
+	case errors.As(e, &riskyAnnotationError):
+		return "RiskyAnnotation"
+	case IsValidationError(e):
+		return "InvalidAnnotation"
+	case IsInvalidAnnotationsContentError(e), errors.As(e, &invalidContentError):
+		return "InvalidAnnotationContent"
+	case IsInvalidIngressContentError(e):
+		return "InvalidIngressContent"
+	case IsMissResourcesError(e):
+		return "MissingResource"
+	case IsNotSatisfiableError(e):
+		return "NotSatisfiable"
+	case IsNginxTestError(e):
+		return "NginxTestFailed"
+	case IsInvalidConfigurationError(e):
+		return "InvalidConfiguration"
+	}
+
+	return "ReconcileError"
+}
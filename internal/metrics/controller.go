@@ -0,0 +1,151 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"strconv"
+	"time"
+)
+
+const (
+	namespace = "ingress_nginx"
+	subsystem = "controller"
+)
+
+var ingressLabels = []string{"namespace", "ingress"}
+
+var (
+	reloadAttempts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "nginx_reload_attempts_total",
+		Help:      "Number of times the controller tried to apply a generated configuration to nginx.",
+	}, ingressLabels)
+
+	reloadSuccesses = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "nginx_reload_success_total",
+		Help:      "Number of configurations that passed nginx -t and reloaded nginx.",
+	}, ingressLabels)
+
+	reloadFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "nginx_reload_failures_total",
+		Help:      "Number of configurations that could not be applied to nginx.",
+	}, ingressLabels)
+
+	reloadRollbacks = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "nginx_reload_rollbacks_total",
+		Help:      "Number of times the previous configuration was restored after nginx -t failed.",
+	}, ingressLabels)
+
+	reloadSkipped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "nginx_reload_skipped_total",
+		Help:      "Number of reconciles whose generated configuration was unchanged, so nginx was not reloaded.",
+	}, ingressLabels)
+
+	renderDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "template_render_duration_seconds",
+		Help:      "Time spent rendering the nginx templates of an ingress.",
+		Buckets:   prometheus.ExponentialBuckets(0.0005, 2, 14),
+	}, ingressLabels)
+
+	servers = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "servers",
+		Help:      "Number of nginx server blocks generated for an ingress.",
+	}, ingressLabels)
+
+	upstreams = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "upstreams",
+		Help:      "Number of nginx upstream blocks generated for an ingress.",
+	}, ingressLabels)
+
+	configHash = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: subsystem,
+		Name:      "config_hash",
+		Help:      "First 52 bits of the SHA1 of the last successfully applied configuration of an ingress.",
+	}, ingressLabels)
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		reloadAttempts,
+		reloadSuccesses,
+		reloadFailures,
+		reloadRollbacks,
+		reloadSkipped,
+		renderDuration,
+		servers,
+		upstreams,
+		configHash,
+	)
+}
+
+// ObserveReload records the outcome of a call to nginx.Reload.
+func ObserveReload(ns, name string, reloaded, rolledBack bool, err error) {
+	reloadAttempts.WithLabelValues(ns, name).Inc()
+
+	switch {
+	case err != nil:
+		reloadFailures.WithLabelValues(ns, name).Inc()
+		if rolledBack {
+			reloadRollbacks.WithLabelValues(ns, name).Inc()
+		}
+	case reloaded:
+		reloadSuccesses.WithLabelValues(ns, name).Inc()
+	default:
+		reloadSkipped.WithLabelValues(ns, name).Inc()
+	}
+}
+
+// ObserveRender records how long rendering the templates of an ingress took.
+func ObserveRender(ns, name string, start time.Time) {
+	renderDuration.WithLabelValues(ns, name).Observe(time.Since(start).Seconds())
+}
+
+// SetConfigState records the shape and the SHA1 of the configuration applied for an ingress.
+// ups maps every upstream name rendered for the ingress to the service(s) behind it.
+func SetConfigState(ns, name string, serverCount int, ups map[string]string, sha1 string) {
+	servers.WithLabelValues(ns, name).Set(float64(serverCount))
+	upstreams.WithLabelValues(ns, name).Set(float64(len(ups)))
+	setUpstreamOwners(ns, name, ups)
+
+	// 13 hex digits are 52 bits, which a float64 represents exactly
+	if len(sha1) >= 13 {
+		if v, err := strconv.ParseUint(sha1[:13], 16, 64); err == nil {
+			configHash.WithLabelValues(ns, name).Set(float64(v))
+		}
+	}
+}
+
+// DeleteIngress drops every series of an ingress that no longer exists.
+func DeleteIngress(ns, name string) {
+	for _, c := range []*prometheus.MetricVec{
+		reloadAttempts.MetricVec,
+		reloadSuccesses.MetricVec,
+		reloadFailures.MetricVec,
+		reloadRollbacks.MetricVec,
+		reloadSkipped.MetricVec,
+		renderDuration.MetricVec,
+		servers.MetricVec,
+		upstreams.MetricVec,
+		configHash.MetricVec,
+	} {
+		c.DeleteLabelValues(ns, name)
+	}
+
+	deleteUpstreamOwners(ns, name)
+}
@@ -0,0 +1,248 @@
+package metrics
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/config"
+	"github.com/prometheus/client_golang/prometheus"
+	"io"
+	"k8s.io/klog/v2"
+	"net/http"
+	"regexp"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	activeRegex   = regexp.MustCompile(`Active connections: (\d+)`)
+	requestsRegex = regexp.MustCompile(`^\s*(\d+)\s+(\d+)\s+(\d+)\s*$`)
+	readingRegex  = regexp.MustCompile(`Reading: (\d+) Writing: (\d+) Waiting: (\d+)`)
+)
+
+// upstreamOwner identifies the ingress and service an upstream generated by server.tmpl belongs to
+type upstreamOwner struct {
+	namespace string
+	ingress   string
+	service   string
+}
+
+var upstreamOwners sync.Map
+
+// RegisterNginxStatusCollector polls the nginx status server on every scrape and republishes
+// stub_status and, when enabled, the vhost traffic status of every upstream.
+func RegisterNginxStatusCollector(enableVTS bool) {
+	metrics.Registry.MustRegister(newNginxStatusCollector(enableVTS))
+}
+
+type nginxStatusCollector struct {
+	client    *http.Client
+	baseURL   string
+	enableVTS bool
+
+	up                *prometheus.Desc
+	connections       *prometheus.Desc
+	connectionsTotal  *prometheus.Desc
+	requestsTotal     *prometheus.Desc
+	upstreamRequests  *prometheus.Desc
+	upstreamResponses *prometheus.Desc
+	upstreamBytes     *prometheus.Desc
+	serverResponses   *prometheus.Desc
+}
+
+func newNginxStatusCollector(enableVTS bool) *nginxStatusCollector {
+	upstreamLabels := []string{"upstream", "server", "namespace", "ingress", "service"}
+
+	return &nginxStatusCollector{
+		client:    &http.Client{Timeout: 5 * time.Second},
+		baseURL:   fmt.Sprintf("http://127.0.0.1:%d", config.StatusPort),
+		enableVTS: enableVTS,
+		up: prometheus.NewDesc("nginx_up",
+			"Whether the last poll of the nginx status server succeeded.", nil, nil),
+		connections: prometheus.NewDesc("nginx_connections",
+			"Current client connections of nginx by state.", []string{"state"}, nil),
+		connectionsTotal: prometheus.NewDesc("nginx_connections_total",
+			"Client connections of nginx since start by outcome.", []string{"state"}, nil),
+		requestsTotal: prometheus.NewDesc("nginx_http_requests_total",
+			"Client requests handled by nginx since start.", nil, nil),
+		upstreamRequests: prometheus.NewDesc("nginx_upstream_requests_total",
+			"Requests proxied to an upstream server.", upstreamLabels, nil),
+		upstreamResponses: prometheus.NewDesc("nginx_upstream_responses_total",
+			"Responses of an upstream server by status class.", append(upstreamLabels, "code"), nil),
+		upstreamBytes: prometheus.NewDesc("nginx_upstream_bytes_total",
+			"Bytes exchanged with an upstream server by direction.", append(upstreamLabels, "direction"), nil),
+		serverResponses: prometheus.NewDesc("nginx_server_responses_total",
+			"Responses of a server_name by status class.", []string{"host", "code"}, nil),
+	}
+}
+
+func (c *nginxStatusCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.up
+	ch <- c.connections
+	ch <- c.connectionsTotal
+	ch <- c.requestsTotal
+	if c.enableVTS {
+		ch <- c.upstreamRequests
+		ch <- c.upstreamResponses
+		ch <- c.upstreamBytes
+		ch <- c.serverResponses
+	}
+}
+
+func (c *nginxStatusCollector) Collect(ch chan<- prometheus.Metric) {
+	if err := c.collectStubStatus(ch); err != nil {
+		klog.V(2).InfoS("fail to poll nginx stub_status", "error", err)
+		ch <- prometheus.MustNewConstMetric(c.up, prometheus.GaugeValue, 0)
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(c.up, prometheus.GaugeValue, 1)
+
+	if c.enableVTS {
+		if err := c.collectVTS(ch); err != nil {
+			klog.V(2).InfoS("fail to poll nginx vhost traffic status", "error", err)
+		}
+	}
+}
+
+func (c *nginxStatusCollector) get(path string) ([]byte, error) {
+	resp, err := c.client.Get(c.baseURL + path)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d polling %s", resp.StatusCode, path)
+	}
+
+	return io.ReadAll(resp.Body)
+}
+
+// collectStubStatus parses the output of the stub_status module:
+//
+//	Active connections: 291
+//	server accepts handled requests
+//	 16630948 16630948 31070465
+//	Reading: 6 Writing: 179 Waiting: 106
+func (c *nginxStatusCollector) collectStubStatus(ch chan<- prometheus.Metric) error {
+	data, err := c.get(config.StatusPath)
+	if err != nil {
+		return err
+	}
+
+	var parsed bool
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if m := activeRegex.FindStringSubmatch(line); m != nil {
+			ch <- prometheus.MustNewConstMetric(c.connections, prometheus.GaugeValue, parseFloat(m[1]), "active")
+			parsed = true
+		} else if m := requestsRegex.FindStringSubmatch(line); m != nil {
+			ch <- prometheus.MustNewConstMetric(c.connectionsTotal, prometheus.CounterValue, parseFloat(m[1]), "accepted")
+			ch <- prometheus.MustNewConstMetric(c.connectionsTotal, prometheus.CounterValue, parseFloat(m[2]), "handled")
+			ch <- prometheus.MustNewConstMetric(c.requestsTotal, prometheus.CounterValue, parseFloat(m[3]))
+		} else if m := readingRegex.FindStringSubmatch(line); m != nil {
+			ch <- prometheus.MustNewConstMetric(c.connections, prometheus.GaugeValue, parseFloat(m[1]), "reading")
+			ch <- prometheus.MustNewConstMetric(c.connections, prometheus.GaugeValue, parseFloat(m[2]), "writing")
+			ch <- prometheus.MustNewConstMetric(c.connections, prometheus.GaugeValue, parseFloat(m[3]), "waiting")
+		}
+	}
+
+	if !parsed {
+		return fmt.Errorf("unexpected stub_status output: %q", data)
+	}
+
+	return nil
+}
+
+type vtsResponses struct {
+	OneXx   float64 `json:"1xx"`
+	TwoXx   float64 `json:"2xx"`
+	ThreeXx float64 `json:"3xx"`
+	FourXx  float64 `json:"4xx"`
+	FiveXx  float64 `json:"5xx"`
+}
+
+func (r vtsResponses) byCode() map[string]float64 {
+	return map[string]float64{"1xx": r.OneXx, "2xx": r.TwoXx, "3xx": r.ThreeXx, "4xx": r.FourXx, "5xx": r.FiveXx}
+}
+
+type vtsStatus struct {
+	ServerZones map[string]struct {
+		Responses vtsResponses `json:"responses"`
+	} `json:"serverZones"`
+	UpstreamZones map[string][]struct {
+		Server         string       `json:"server"`
+		RequestCounter float64      `json:"requestCounter"`
+		InBytes        float64      `json:"inBytes"`
+		OutBytes       float64      `json:"outBytes"`
+		Responses      vtsResponses `json:"responses"`
+	} `json:"upstreamZones"`
+}
+
+func (c *nginxStatusCollector) collectVTS(ch chan<- prometheus.Metric) error {
+	data, err := c.get(config.VTSPath + "/format/json")
+	if err != nil {
+		return err
+	}
+
+	var status vtsStatus
+	if err := json.Unmarshal(data, &status); err != nil {
+		return err
+	}
+
+	for host, zone := range status.ServerZones {
+		if host == "*" {
+			continue
+		}
+		for code, v := range zone.Responses.byCode() {
+			ch <- prometheus.MustNewConstMetric(c.serverResponses, prometheus.CounterValue, v, host, code)
+		}
+	}
+
+	for upstream, peers := range status.UpstreamZones {
+		owner := upstreamOwner{}
+		if v, ok := upstreamOwners.Load(upstream); ok {
+			owner = v.(upstreamOwner)
+		}
+
+		for _, peer := range peers {
+			labels := []string{upstream, peer.Server, owner.namespace, owner.ingress, owner.service}
+			ch <- prometheus.MustNewConstMetric(c.upstreamRequests, prometheus.CounterValue, peer.RequestCounter, labels...)
+			ch <- prometheus.MustNewConstMetric(c.upstreamBytes, prometheus.CounterValue, peer.InBytes, append(labels, "in")...)
+			ch <- prometheus.MustNewConstMetric(c.upstreamBytes, prometheus.CounterValue, peer.OutBytes, append(labels, "out")...)
+			for code, v := range peer.Responses.byCode() {
+				ch <- prometheus.MustNewConstMetric(c.upstreamResponses, prometheus.CounterValue, v, append(labels, code)...)
+			}
+		}
+	}
+
+	return nil
+}
+
+func parseFloat(s string) float64 {
+	v, _ := strconv.ParseFloat(s, 64)
+	return v
+}
+
+// setUpstreamOwners remembers which ingress and service the upstreams of an ingress belong to,
+// keyed by the upstream names server.tmpl generates.
+func setUpstreamOwners(ns, name string, upstreams map[string]string) {
+	deleteUpstreamOwners(ns, name)
+	for upstream, svc := range upstreams {
+		upstreamOwners.Store(upstream, upstreamOwner{namespace: ns, ingress: name, service: svc})
+	}
+}
+
+func deleteUpstreamOwners(ns, name string) {
+	upstreamOwners.Range(func(key, value any) bool {
+		if o := value.(upstreamOwner); o.namespace == ns && o.ingress == name {
+			upstreamOwners.Delete(key)
+		}
+		return true
+	})
+}
@@ -0,0 +1,101 @@
+package nginx
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/config"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Backend is an upstream balanced by balancer.lua together with its current peers
+type Backend struct {
+	Name      string     `json:"name"`
+	Endpoints []Endpoint `json:"endpoints"`
+}
+
+// Endpoint is a peer of a Backend, a zero weight marks it down
+type Endpoint struct {
+	Address string `json:"address"`
+	Port    string `json:"port"`
+	Weight  int    `json:"weight"`
+}
+
+var backendsClient = &http.Client{Timeout: 5 * time.Second}
+
+// pushed holds the backends of every ingress, a failed push is retried by the next one. balancer.lua
+// replaces all of its backends with the posted ones, so each push carries the ones of the other ingresses too.
+var pushed = struct {
+	sync.Mutex
+	backends map[string][]Backend
+}{backends: make(map[string][]Backend)}
+
+// SetBackends pushes the backends of the ingress owner together with the ones of the other ingresses
+func SetBackends(owner string, backends []Backend) error {
+	pushed.Lock()
+	defer pushed.Unlock()
+
+	pushed.backends[owner] = backends
+
+	return ConfigureBackends(pushedBackends())
+}
+
+// DeleteBackends removes the backends of the ingress owner from nginx
+func DeleteBackends(owner string) error {
+	pushed.Lock()
+	defer pushed.Unlock()
+
+	if _, ok := pushed.backends[owner]; !ok {
+		return nil
+	}
+
+	delete(pushed.backends, owner)
+
+	return ConfigureBackends(pushedBackends())
+}
+
+func pushedBackends() []Backend {
+	owners := make([]string, 0, len(pushed.backends))
+	for owner := range pushed.backends {
+		owners = append(owners, owner)
+	}
+	sort.Strings(owners)
+
+	backends := make([]Backend, 0)
+	for _, owner := range owners {
+		backends = append(backends, pushed.backends[owner]...)
+	}
+
+	return backends
+}
+
+// ConfigureBackends pushes the peers of the dynamic upstreams to the running nginx, which
+// switches to them on the next request without a reload. The backends not in the list are removed.
+func ConfigureBackends(backends []Backend) error {
+	if backends == nil {
+		backends = []Backend{}
+	}
+
+	data, err := json.Marshal(backends)
+	if err != nil {
+		return err
+	}
+
+	url := fmt.Sprintf("http://127.0.0.1:%d%s", config.GetGlobal().StatusPort, config.BackendsPath)
+	resp, err := backendsClient.Post(url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("fail to push the backends to nginx: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("fail to push the backends to nginx, status: %d, response: %s", resp.StatusCode, body)
+	}
+
+	return nil
+}
@@ -0,0 +1,123 @@
+package nginx
+
+import (
+	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/config"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// TestConfigureBackends checks the body posted to balancer.lua configure, which reads
+// backend.name, endpoint.address, tonumber(endpoint.port) and tonumber(endpoint.weight)
+func TestConfigureBackends(t *testing.T) {
+	tests := []struct {
+		name     string
+		backends []Backend
+		status   int
+		want     string
+		wantErr  bool
+	}{
+		{
+			name: "peers",
+			backends: []Backend{
+				{Name: "web-demo-default", Endpoints: []Endpoint{
+					{Address: "10.0.0.1", Port: "8080", Weight: 1},
+					{Address: "10.0.0.2", Port: "8080", Weight: 0},
+				}},
+				{Name: "api-demo-default", Endpoints: []Endpoint{}},
+			},
+			status: http.StatusCreated,
+			want: `[{"name":"web-demo-default","endpoints":[{"address":"10.0.0.1","port":"8080","weight":1},` +
+				`{"address":"10.0.0.2","port":"8080","weight":0}]},{"name":"api-demo-default","endpoints":[]}]`,
+		},
+		{
+			name:     "rejected",
+			backends: []Backend{{Name: "web-demo-default"}},
+			status:   http.StatusBadRequest,
+			want:     `[{"name":"web-demo-default","endpoints":null}]`,
+			wantErr:  true,
+		},
+		{
+			name:   "no backends left",
+			status: http.StatusCreated,
+			want:   `[]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodPost || r.URL.Path != config.BackendsPath {
+					t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+				}
+				b, _ := io.ReadAll(r.Body)
+				got = string(b)
+				w.WriteHeader(tt.status)
+			}))
+			defer srv.Close()
+
+			prev := config.GetGlobal()
+			defer config.SetGlobal(prev)
+			g := config.NewGlobal()
+			_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+			g.StatusPort, _ = strconv.Atoi(port)
+			config.SetGlobal(g)
+
+			err := ConfigureBackends(tt.backends)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ConfigureBackends() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ConfigureBackends() posted %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestSetBackends checks every push carries the backends of all the ingresses
+func TestSetBackends(t *testing.T) {
+	var got []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		got = append(got, string(b))
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	prev := config.GetGlobal()
+	defer config.SetGlobal(prev)
+	g := config.NewGlobal()
+	_, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	g.StatusPort, _ = strconv.Atoi(port)
+	config.SetGlobal(g)
+
+	web := []Backend{{Name: "web-demo-default", Endpoints: []Endpoint{}}}
+	api := []Backend{{Name: "api-other-default", Endpoints: []Endpoint{}}}
+	steps := []func() error{
+		func() error { return SetBackends("default/demo", web) },
+		func() error { return SetBackends("default/other", api) },
+		func() error { return DeleteBackends("default/demo") },
+		func() error { return DeleteBackends("default/demo") },
+		func() error { return DeleteBackends("default/other") },
+	}
+	for _, step := range steps {
+		if err := step(); err != nil {
+			t.Fatalf("push failed: %v", err)
+		}
+	}
+
+	want := []string{
+		`[{"name":"web-demo-default","endpoints":[]}]`,
+		`[{"name":"web-demo-default","endpoints":[]},{"name":"api-other-default","endpoints":[]}]`,
+		`[{"name":"api-other-default","endpoints":[]}]`,
+		`[]`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("pushed %v, want %v", got, want)
+	}
+}
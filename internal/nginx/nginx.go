@@ -4,11 +4,13 @@ import (
 	"errors"
 	"fmt"
 	"github.com/Lxb921006/ingress-nginx-kubebuilder/internal/config"
+	kerr "github.com/Lxb921006/ingress-nginx-kubebuilder/internal/errors"
 	"github.com/Lxb921006/ingress-nginx-kubebuilder/pkg/utils/file"
 	"github.com/mitchellh/go-ps"
 	"k8s.io/klog/v2"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"syscall"
@@ -65,11 +67,13 @@ func CleanConf(files ...string) {
 
 }
 
-func Reload(name string) error {
+// Reload verifies the generated <name>-test.conf with nginx -t and swaps it in.
+// It reports false without error when the configuration has not changed and nginx was not reloaded.
+func Reload(name string) (bool, error) {
 	return reload(name)
 }
 
-func reload(name string) error {
+func reload(name string) (bool, error) {
 	var isFirstReload bool
 	var exitError *exec.ExitError
 
@@ -82,38 +86,38 @@ func reload(name string) error {
 	if _, err := os.Stat(productConf); err == nil {
 		if file.SHA1(productConf) == file.SHA1(testConf) {
 			klog.Info(fmt.Sprintf("%s has not changed, no need to reload nginx", productConf))
-			return err
+			return false, nil
 		}
 
 		if err := backupConf(productConf, testConf, backupFile); err != nil {
-			return err
+			return false, err
 		}
 	} else {
 		isFirstReload = true
 	}
 
-	cmd := exec.Command(config.Bin, "-t")
-	if err := cmd.Run(); err != nil {
+	output, err := exec.Command(config.Bin, "-t").CombinedOutput()
+	if err != nil {
 		if errors.As(err, &exitError) {
-			klog.ErrorS(err, fmt.Sprintf("nginx configuration: %s file verification fails, pls check", productConf))
+			klog.ErrorS(err, fmt.Sprintf("nginx configuration: %s file verification fails, pls check", productConf), "output", string(output))
 			if !isFirstReload {
 				if err := rolloutConf(backupFile, productConf); err != nil {
-					return err
+					return false, err
 				}
 			}
-			return err
+			return false, kerr.NewNginxTestError(filepath.Base(productConf), output, !isFirstReload)
 		}
 	}
 
 	if err := generateConf(testConf, productConf); err != nil {
-		return err
+		return false, err
 	}
 
 	if err := gracefulRestart(); err != nil {
-		return err
+		return false, err
 	}
 
-	return nil
+	return true, nil
 }
 
 func reloadIfWatchFileCurd() {
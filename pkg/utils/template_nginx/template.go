@@ -12,6 +12,8 @@ type RenderTemplate struct {
 	GenerateName       string
 	RenderTemplateName string
 	MainTemplateName   string
+	// MainData is the data handed to the main template
+	MainData interface{}
 }
 
 func (rt *RenderTemplate) Render(data interface{}) error {
@@ -54,7 +56,7 @@ func (rt *RenderTemplate) Render(data interface{}) error {
 	}
 
 	var mainTpl bytes.Buffer
-	if err = mainTmpl.Execute(&mainTpl, nil); err != nil {
+	if err = mainTmpl.Execute(&mainTpl, rt.MainData); err != nil {
 		klog.ErrorS(err, fmt.Sprintf("rendering %s template_nginx failed", rt.RenderTemplateName))
 		return err
 	}
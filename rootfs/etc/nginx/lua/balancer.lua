@@ -0,0 +1,198 @@
+-- balancer picks the peer of the upstreams rendered with balancer_by_lua_block.
+-- The controller pushes the ready endpoints of every upstream to /configuration/backends,
+-- so scaling a service changes the peers without reloading nginx.
+local ngx_balancer = require("ngx.balancer")
+local cjson = require("cjson.safe")
+
+local configuration_data = ngx.shared.configuration_data
+
+local _M = {}
+
+-- per worker state of every upstream, rebuilt whenever the pushed configuration changes
+local backends = {}
+
+local function read_body()
+  ngx.req.read_body()
+
+  local body = ngx.req.get_body_data()
+  if body then
+    return body
+  end
+
+  local file_name = ngx.req.get_body_file()
+  if not file_name then
+    return nil
+  end
+
+  local file = io.open(file_name, "rb")
+  if not file then
+    return nil
+  end
+
+  body = file:read("*all")
+  file:close()
+
+  return body
+end
+
+-- configure stores the backends posted by the controller and removes the ones it no longer posts:
+-- [{"name": "<upstream>", "endpoints": [{"address": "10.0.0.1", "port": "8080", "weight": 1}]}]
+function _M.configure()
+  if ngx.var.request_method ~= "POST" then
+    ngx.status = ngx.HTTP_NOT_ALLOWED
+    return ngx.print("only POST is allowed")
+  end
+
+  local list, err = cjson.decode(read_body() or "")
+  if type(list) ~= "table" then
+    ngx.log(ngx.ERR, "invalid backends configuration: ", err)
+    ngx.status = ngx.HTTP_BAD_REQUEST
+    return ngx.print("invalid backends configuration")
+  end
+
+  local names = {}
+  for _, backend in ipairs(list) do
+    local key = "backend:" .. backend.name
+    local ok, set_err = configuration_data:set(key, cjson.encode(backend))
+    if not ok then
+      ngx.log(ngx.ERR, "fail to store backend ", backend.name, ": ", set_err)
+      ngx.status = ngx.HTTP_INTERNAL_SERVER_ERROR
+      return ngx.print(set_err)
+    end
+    names[key] = true
+  end
+
+  for _, key in ipairs(configuration_data:get_keys(0)) do
+    if key:sub(1, 8) == "backend:" and not names[key] then
+      configuration_data:delete(key)
+    end
+  end
+
+  ngx.status = ngx.HTTP_CREATED
+end
+
+-- points of the heaviest peer on the hash ring, the others get a share proportional to their weight
+local RING_POINTS = 160
+
+local function build_ring(peers)
+  local max_weight = 0
+  for _, peer in ipairs(peers) do
+    max_weight = math.max(max_weight, peer.weight)
+  end
+
+  local ring = {}
+  for _, peer in ipairs(peers) do
+    local id = peer.address .. ":" .. peer.port
+    local points = math.max(1, math.floor(peer.weight / max_weight * RING_POINTS + 0.5))
+    for i = 1, points do
+      table.insert(ring, { hash = ngx.crc32_long(id .. "#" .. i), peer = peer })
+    end
+  end
+
+  table.sort(ring, function(a, b) return a.hash < b.hash end)
+
+  return ring
+end
+
+local function build(raw)
+  local backend = cjson.decode(raw)
+  if type(backend) ~= "table" then
+    return nil
+  end
+
+  local peers = {}
+  for _, endpoint in ipairs(backend.endpoints or {}) do
+    local weight = tonumber(endpoint.weight) or 1
+    if weight > 0 then
+      table.insert(peers, {
+        address = endpoint.address,
+        port = tonumber(endpoint.port),
+        weight = weight,
+        current = 0,
+      })
+    end
+  end
+
+  return { raw = raw, peers = peers, ring = build_ring(peers) }
+end
+
+-- smooth weighted round robin, the algorithm nginx uses for its own upstreams
+local function next_peer(backend)
+  local total, best = 0, nil
+
+  for _, peer in ipairs(backend.peers) do
+    peer.current = peer.current + peer.weight
+    total = total + peer.weight
+    if best == nil or peer.current > best.current then
+      best = peer
+    end
+  end
+
+  if best then
+    best.current = best.current - total
+  end
+
+  return best
+end
+
+-- consistent hashing, the peer of the first point of the ring at or after the hash of key,
+-- so a change of the peers only moves the keys of the points it adds or removes
+local function hash_peer(backend, key)
+  local ring = backend.ring
+  local hash = ngx.crc32_long(key)
+  if hash > ring[#ring].hash then
+    return ring[1].peer
+  end
+
+  local lo, hi = 1, #ring
+  while lo < hi do
+    local mid = math.floor((lo + hi) / 2)
+    if ring[mid].hash < hash then
+      lo = mid + 1
+    else
+      hi = mid
+    end
+  end
+
+  return ring[lo].peer
+end
+
+-- balance sets the peer of the request, picked by the value of the variable key_var when the
+-- upstream has an affinity and the request has one, by weighted round robin otherwise
+function _M.balance(name, key_var)
+  local raw = configuration_data:get("backend:" .. name)
+  if not raw then
+    ngx.log(ngx.WARN, "no endpoints have been pushed for upstream ", name)
+    backends[name] = nil
+    return ngx.exit(ngx.HTTP_SERVICE_UNAVAILABLE)
+  end
+
+  local backend = backends[name]
+  if backend == nil or backend.raw ~= raw then
+    backend = build(raw)
+    backends[name] = backend
+  end
+
+  if backend == nil or #backend.peers == 0 then
+    ngx.log(ngx.WARN, "upstream ", name, " has no available endpoints")
+    return ngx.exit(ngx.HTTP_SERVICE_UNAVAILABLE)
+  end
+
+  local peer
+  if key_var then
+    local key = ngx.var[key_var]
+    if key and key ~= "" then
+      peer = hash_peer(backend, key)
+    end
+  end
+  peer = peer or next_peer(backend)
+
+  ngx_balancer.set_more_tries(1)
+
+  local ok, err = ngx_balancer.set_current_peer(peer.address, peer.port)
+  if not ok then
+    ngx.log(ngx.ERR, "fail to set the current peer of upstream ", name, ": ", err)
+  end
+end
+
+return _M